@@ -0,0 +1,35 @@
+package cmd
+
+import "path/filepath"
+
+type CgroupOptions struct {
+	Parent string   `json:"parent"` //父 cgroup，相对 /sys/fs/cgroup 的路径，默认当前进程所在的 cgroup
+	Name   string   `json:"name"`   //子 cgroup 名称的前缀，每次运行加上 -PID-序号，默认 Cmd 名称或执行文件名，不能为 supervisor
+	Memory FileSize `json:"memory"` //memory.max，0不限制
+	CPU    float64  `json:"cpu"`    //cpu.max，可用的CPU核数，如 1.5，0不限制
+	Pids   int      `json:"pids"`   //pids.max，0不限制
+}
+
+// 每次运行时创建独立的 cgroup v2 子树并在 clone 时直接放入(linux 5.7+)，设置资源限制；
+// 停止时向 cgroup 中所有进程发送信号，退出后清理残留进程并删除 cgroup，
+// 发生过 OOM 时 ExitResult.OOMKilled 为 true；cgroup v2 不可写时启动失败并返回错误。
+// 需要启用控制器而父 cgroup 中有当前进程以外的进程时也会失败，此时应指定委派给当前用户的 Parent
+func (c *Cmd) Cgroup(options CgroupOptions) *Cmd {
+	cg := &cgroup{options: options, cmd: c}
+	return c.With(runOption(cg.prepare))
+}
+
+type cgroup struct {
+	options CgroupOptions
+	cmd     *Cmd
+}
+
+func (cg *cgroup) name() string {
+	if cg.options.Name != "" {
+		return cg.options.Name
+	}
+	if cg.cmd.name != "" {
+		return cg.cmd.name
+	}
+	return filepath.Base(cg.cmd.executable)
+}
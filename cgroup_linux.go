@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+var cgroupSeq atomic.Int64
+
+// 创建本次运行的 cgroup，clone 时直接放入
+func (cg *cgroup) prepare(cmd *exec.Cmd, r *run) error {
+	dir, err := cg.create()
+	if err != nil {
+		return err
+	}
+	r.cleanup.Append(dir.cleanup) //启动失败时也会执行
+	r.signalers = append(r.signalers, dir.signal)
+	r.exited = append(r.exited, dir.exited)
+
+	fd, err := os.Open(string(dir))
+	if err != nil {
+		return fmt.Errorf("cgroup %s: %w", dir, err)
+	}
+	//启动后不再需要目录的文件描述符
+	r.started = append(r.started, func(int) error { fd.Close(); return nil })
+	r.cleanup.Append(func() { fd.Close() })
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+	return nil
+}
+
+// 本次运行的 cgroup 目录
+type cgroupDir string
+
+func (cg *cgroup) create() (cgroupDir, error) {
+	name, err := cg.runName()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+
+	parent := cg.options.Parent
+	if parent == "" {
+		self, err := selfCgroup()
+		if err != nil {
+			return "", err
+		}
+		parent = self
+	}
+	parent = filepath.Join(cgroupRoot, parent)
+
+	if err := enableControllers(parent, cg.controllers()); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not writable: %w", err)
+	}
+
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not writable: %w", err)
+	}
+
+	for file, value := range cgroupLimits(cg.options) {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+			os.Remove(dir)
+			return "", fmt.Errorf("cgroup %s: %w", file, err)
+		}
+	}
+	return cgroupDir(dir), nil
+}
+
+// 本次运行的 cgroup 名称；同一个 Cmd 可能同时运行多次，加上 PID 和序号区分，
+// 不能与 enableControllers 创建的 supervisor 冲突
+func (cg *cgroup) runName() (string, error) {
+	name := cg.name()
+	if name == "supervisor" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return "", fmt.Errorf("cgroup name %q is not allowed", name)
+	}
+	return fmt.Sprintf("%s-%d-%d", name, os.Getpid(), cgroupSeq.Add(1)), nil
+}
+
+// 资源限制对应的文件和内容
+func cgroupLimits(o CgroupOptions) map[string]string {
+	limits := map[string]string{}
+	if o.Memory > 0 {
+		limits["memory.max"] = strconv.FormatInt(int64(o.Memory), 10)
+	}
+	if o.CPU > 0 {
+		const period = 100000
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int64(o.CPU*period), period)
+	}
+	if o.Pids > 0 {
+		limits["pids.max"] = strconv.Itoa(o.Pids)
+	}
+	return limits
+}
+
+func (cg *cgroup) controllers() (names []string) {
+	if cg.options.Memory > 0 {
+		names = append(names, "memory")
+	}
+	if cg.options.CPU > 0 {
+		names = append(names, "cpu")
+	}
+	if cg.options.Pids > 0 {
+		names = append(names, "pids")
+	}
+	return
+}
+
+// 向 cgroup 中不在进程组 pgid 中的进程发送信号(进程组已单独发送过)，SIGKILL 使用 cgroup.kill(linux 5.14+)
+func (dir cgroupDir) signal(pgid int, sig syscall.Signal) {
+	if sig == syscall.SIGKILL && dir.write("cgroup.kill", "1") == nil {
+		return
+	}
+	for _, pid := range cgroupProcs(string(dir)) {
+		if g, err := syscall.Getpgid(pid); err == nil && g != pgid {
+			syscall.Kill(pid, sig)
+		}
+	}
+}
+
+// 主进程退出后记录 OOM
+func (dir cgroupDir) exited(r *ExitResult) {
+	data, _ := os.ReadFile(filepath.Join(string(dir), "memory.events"))
+	if oomKilled(data) {
+		r.OOMKilled = true
+	}
+}
+
+// memory.events 中 oom_kill 不为0
+func oomKilled(events []byte) bool {
+	for _, line := range strings.Split(string(events), "\n") {
+		if n, ok := strings.CutPrefix(line, "oom_kill "); ok && strings.TrimSpace(n) != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// 结束残留的进程并删除 cgroup
+func (dir cgroupDir) cleanup() {
+	for i := 0; i < 50 && cgroupPopulated(string(dir)); i++ {
+		if i == 0 {
+			if dir.write("cgroup.kill", "1") != nil {
+				for _, pid := range cgroupProcs(string(dir)) {
+					syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	os.Remove(string(dir))
+}
+
+func (dir cgroupDir) write(file, value string) error {
+	return os.WriteFile(filepath.Join(string(dir), file), []byte(value), 0644)
+}
+
+// 当前进程所在的 cgroup
+func selfCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	return parseCgroup(data)
+}
+
+// 从 /proc/<pid>/cgroup 中取出 cgroup v2 的路径
+func parseCgroup(data []byte) (string, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not in use by the current process")
+}
+
+// 在父 cgroup 中启用控制器；按 cgroup v2 的规定有进程的 cgroup 不能给子 cgroup 启用控制器，
+// 父 cgroup 中只有当前进程时把它移到子 cgroup supervisor 中，有其他进程时不移动并返回错误，需指定委派的 Parent
+func enableControllers(parent string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(data))
+
+	var add []string
+	for _, name := range names {
+		if !slices.Contains(enabled, name) {
+			add = append(add, "+"+name)
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+
+	control := filepath.Join(parent, "cgroup.subtree_control")
+	err = os.WriteFile(control, []byte(strings.Join(add, " ")), 0644)
+	if !errors.Is(err, syscall.EBUSY) {
+		return err
+	}
+
+	self := os.Getpid()
+	if procs := cgroupProcs(parent); len(procs) == 0 || slices.ContainsFunc(procs, func(pid int) bool { return pid != self }) {
+		return fmt.Errorf("%s contains other processes, set a delegated parent: %w", parent, err)
+	}
+
+	leaf := filepath.Join(parent, "supervisor")
+	if err = os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(self)), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(control, []byte(strings.Join(add, " ")), 0644)
+}
+
+func cgroupProcs(dir string) []int {
+	data, _ := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	return parsePids(data)
+}
+
+// 每行一个 PID
+func parsePids(data []byte) (pids []int) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if pid, err := strconv.Atoi(s.Text()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return
+}
+
+func cgroupPopulated(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.events"))
+	return err == nil && bytes.Contains(data, []byte("populated 1"))
+}
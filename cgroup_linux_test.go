@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCgroupParse(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		oom  bool
+	}{
+		{"low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n", false},
+		{"low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\noom_group_kill 0\n", true},
+		{"", false}, //没有启用 memory 控制器
+	} {
+		if got := oomKilled([]byte(c.data)); got != c.oom {
+			t.Errorf("%q: oom %v", c.data, got)
+		}
+	}
+
+	path, err := parseCgroup([]byte("12:pids:/init.scope\n0::/user.slice/user-1000.slice/session-1.scope\n"))
+	if err != nil || path != "/user.slice/user-1000.slice/session-1.scope" {
+		t.Errorf("cgroup %q %v", path, err)
+	}
+	if _, err = parseCgroup([]byte("12:pids:/init.scope\n")); err == nil {
+		t.Error("cgroup v1 only: no error")
+	}
+
+	if pids := parsePids([]byte("1\n42\n\n")); !reflect.DeepEqual(pids, []int{1, 42}) {
+		t.Errorf("pids %v", pids)
+	}
+}
+
+func TestCgroupLimits(t *testing.T) {
+	limits := cgroupLimits(CgroupOptions{Memory: 64 << 20, CPU: 1.5, Pids: 100})
+	want := map[string]string{"memory.max": "67108864", "cpu.max": "150000 100000", "pids.max": "100"}
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("limits %v", limits)
+	}
+	if limits = cgroupLimits(CgroupOptions{}); len(limits) != 0 {
+		t.Errorf("no limits %v", limits)
+	}
+}
+
+// 模拟的 cgroup 目录，退出后读取 OOM
+func TestCgroupExited(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "memory.events"), []byte("oom 1\noom_kill 2\n"), 0644)
+
+	var r ExitResult
+	cgroupDir(dir).exited(&r)
+	if !r.OOMKilled {
+		t.Error("oom not recorded")
+	}
+}
+
+func TestCgroupRunName(t *testing.T) {
+	cg := &cgroup{cmd: Shell("true")}
+	a, err := cg.runName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := cg.runName(); a == b || !strings.HasPrefix(a, filepath.Base(cg.cmd.executable)+"-") {
+		t.Errorf("run names %q %q", a, b)
+	}
+
+	for _, name := range []string{"supervisor", "a/b", ".."} {
+		cg := &cgroup{options: CgroupOptions{Name: name}, cmd: Shell("true")}
+		if _, err := cg.runName(); err == nil {
+			t.Errorf("%q: no error", name)
+		}
+	}
+}
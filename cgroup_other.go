@@ -0,0 +1,12 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func (cg *cgroup) prepare(*exec.Cmd, *run) error {
+	return errors.New("cgroup v2 is only supported on linux")
+}
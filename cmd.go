@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -35,8 +38,20 @@ type Cmd struct {
 	pid        Pid      //指定PIDFile路径
 	postStart  Runner   //启动后执行
 	preExit    Runner   //完成时执行
+	onStatus   []func(state *StartState, t Transition)
+	stopPolicy StopPolicy //停止策略，默认 DefaultStopPolicy
+	clock      Clock
+	ready      ReadyOptions  //就绪探针
+	health     HealthOptions //存活检查
+
+	lineMax      int  //按行读取的最大长度
+	lineTruncate bool //超长时截断，否则拆分
+	outputHead   int  //Output 缓存保留的开头字节数
+	outputTail   int  //Output 缓存保留的末尾字节数
+	stdinPipe    bool //启动后可通过 StartState.Stdin() 写入
+
+	logBytes atomic.Int64 //Logger 写入日志文件的总字节数
 
-	err error
 	// cmd *exec.Cmd
 }
 
@@ -52,7 +67,17 @@ func (c *Cmd) Run() (state *StartState) {
 
 // 启动进程
 func (c *Cmd) RunWithContext(ctx context.Context) (state *StartState) {
-	state = &StartState{}
+	return c.run(ctx)
+}
+
+// 启动进程，extra 只对本次运行生效
+func (c *Cmd) run(ctx context.Context, extra ...Option) (state *StartState) {
+	state = &StartState{ready: make(chan struct{}), stopStep: -1}
+	for _, fn := range c.onStatus {
+		fn := fn
+		state.status.hooks = append(state.status.hooks, func(t Transition) { fn(state, t) })
+	}
+	state.status.set(StatusStarting)
 
 	ctx, state.cancel = context.WithCancel(ctx)
 	cmd := exec.Command(c.executable, c.args...)
@@ -64,14 +89,19 @@ func (c *Cmd) RunWithContext(ctx context.Context) (state *StartState) {
 		allDone = make(chan struct{})
 	)
 
-	c.err = nil
 	// c.cmd = cmd
 
+	r := &run{}
+	options := append(c.options[:len(c.options):len(c.options)], extra...)
+
 	state.done = allDone
 	handleExit := func(err error) *StartState {
 		defer close(cmdDone)
+		if reason := state.failure(); reason != nil {
+			err = failed(reason, err)
+		}
 		state.Err = err
-		c.err = err
+		state.status.set(StatusStopped)
 		return state
 	}
 
@@ -83,29 +113,79 @@ func (c *Cmd) RunWithContext(ctx context.Context) (state *StartState) {
 		close(allDone)
 	}()
 
-	c.options.Apply(cmd)
+	options.prepare(cmd, r)
 	if cmd.Err != nil {
+		r.cleanup.Run() //释放本次运行中选项创建的资源
 		return handleExit(cmd.Err)
 	}
 
+	ready, err := c.ready.prepare(cmd, c.lineMax, c.lineTruncate)
+	if err == nil && c.stdinPipe {
+		state.stdin, err = cmd.StdinPipe()
+	}
+	if err == nil {
+		err = r.beforeStart(cmd)
+	}
+	if err != nil {
+		r.cleanup.Run()
+		return handleExit(err)
+	}
+
 	if err := cmd.Start(); err != nil {
+		r.cleanup.Run()
 		return handleExit(err)
 	}
+	startTime := time.Now()
 
 	pid := cmd.Process.Pid
 	state.PID = pid
+	if err := r.start(pid); err != nil {
+		state.fail(err) //进程已启动，按停止策略结束
+	}
+	state.status.set(StatusStarted)
 
 	c.pid.WritePid(pid)
-	c.preExit.Append(c.pid.DelPid)
+	r.cleanup.Append(c.pid.DelPid)
 
 	//terminate when context done
-	bgRun(&w, waitTerminate(ctx, pid, cmdDone))
+	bgRun(&w, waitTerminate(ctx, state, cmdDone, c, r.signal))
 
-	//read console and wait done
+	//wait process exit
+	var (
+		exited  = make(chan struct{})
+		waitErr error
+	)
+	probeCtx, probeCancel := context.WithCancel(ctx)
 	bgRun(&w, func() {
-		c.postStart.Run()
-		handleExit(cmd.Wait())
+		waitErr = cmd.Wait()
+		state.mu.Lock()
+		state.result = newExitResult(cmd.ProcessState, startTime, time.Now(), state.stopStep)
+		for _, fn := range r.exited {
+			fn(state.result)
+		}
+		state.mu.Unlock()
+		probeCancel()
+		close(exited)
+	})
+
+	//wait ready, run hooks and wait done
+	bgRun(&w, func() {
+		if err := ready(probeCtx, state); err != nil {
+			if probeCtx.Err() == nil { //进程已退出或被取消时不算就绪失败
+				state.fail(err)
+			}
+		} else {
+			state.mu.Lock()
+			state.readyAt = time.Now()
+			state.mu.Unlock()
+			close(state.ready)
+			bgRun(&w, c.health.watch(probeCtx, state))
+			c.postStart.Run()
+		}
+		<-exited
+		handleExit(waitErr)
 		c.preExit.Run()
+		r.cleanup.Run()
 	})
 
 	return
@@ -123,22 +203,21 @@ func (c *Cmd) String() string {
 }
 
 type StartState struct {
-	PID    int
-	Err    error
-	Status Status
+	PID int
+	Err error
 
+	status statusMachine
+	ready  chan struct{}
+	stdin  io.WriteCloser
 	done   <-chan struct{}
 	cancel context.CancelFunc
-}
 
-type Status string
-
-const (
-	StatusStarting Status = "starting"
-	StatusStarted  Status = "started"
-	StatusStopping Status = "stopping"
-	StatusStopped  Status = "stopped"
-)
+	mu       sync.Mutex
+	reason   error //主动结束进程的原因
+	result   *ExitResult
+	stopStep int
+	readyAt  time.Time
+}
 
 func (s *StartState) Done() <-chan struct{} {
 	return s.done
@@ -148,38 +227,42 @@ func (s *StartState) Cancel() {
 	s.cancel()
 }
 
+// 记录失败原因并结束进程，原因会合并到 Err 中
+func (s *StartState) fail(reason error) {
+	s.mu.Lock()
+	if s.reason == nil {
+		s.reason = reason
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+func (s *StartState) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+func failed(reason, err error) error {
+	if err == nil {
+		return reason
+	}
+	return fmt.Errorf("%w: %w", reason, err)
+}
+
 func (s *StartState) Wait() error {
 	<-s.Done()
 	return s.Err
 }
 
-func waitTerminate(ctx context.Context, pid int, done <-chan struct{}) func() {
+func waitTerminate(ctx context.Context, state *StartState, done <-chan struct{}, c *Cmd, signal func(pid int, sig syscall.Signal) error) func() {
 	return func() {
 		select {
 		case <-done:
 			return
 		case <-ctx.Done():
-			Terminate(pid, done)
-		}
-	}
-}
-
-func Terminate(pid int, done <-chan struct{}) {
-	sysInterrupt(pid)
-
-	if done != nil {
-		select {
-		case <-done:
-			return
-		case <-time.After(time.Second * 3):
-			sysTerminate(pid)
-		}
-
-		select {
-		case <-done:
-			return
-		case <-time.After(time.Second * 2):
-			sysKill(pid)
+			state.status.set(StatusStopping)
+			c.stopPolicy.terminate(state.PID, done, c.clock, state.setStopStep, signal)
 		}
 	}
 }
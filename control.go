@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 每个成员保留的最近输出字节数
+const controlLogSize = 256 << 10
+
+// 控制接口，通过 Unix 套接字以 HTTP+JSON 提供组内成员的查询和启停
+//
+//	GET  /processes                     所有成员的状态
+//	GET  /processes/{name}              单个成员的状态
+//	POST /processes/{name}/start        启动
+//	POST /processes/{name}/stop         停止
+//	POST /processes/{name}/restart      重启
+//	POST /processes/{name}/signal?sig=  发送信号，如 HUP、SIGUSR1、15
+//	GET  /processes/{name}/logs?lines=  最近的输出，默认100行
+func NewControl(g *Group) *Control {
+	ctl := &Control{g: g, logs: map[string]*Buffer{}}
+	for _, m := range g.members {
+		buf := NewBuffer(0, controlLogSize)
+		m.cmd.Tee(buf)
+		ctl.logs[m.name] = buf
+	}
+	return ctl
+}
+
+type Control struct {
+	g    *Group
+	logs map[string]*Buffer
+}
+
+// 在 Unix 套接字上提供服务，ctx 结束时关闭并删除套接字文件
+func (ctl *Control) Serve(ctx context.Context, path string) error {
+	//上次异常退出留下的套接字文件
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("control socket %s already in use", path)
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	os.Chmod(path, 0600)
+
+	srv := &http.Server{Handler: ctl}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err = srv.Serve(ln); errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+func (ctl *Control) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "processes" || len(parts) > 3 {
+		ctlError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			ctlError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		ctlJSON(w, ctl.g.Status())
+		return
+	}
+
+	name := parts[1]
+	m := ctl.g.index[name]
+	if m == nil {
+		ctlError(w, http.StatusNotFound, fmt.Errorf("unknown process %s", name))
+		return
+	}
+
+	var action string
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	if action == "" || action == "logs" {
+		if r.Method != http.MethodGet {
+			ctlError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		if action == "" {
+			ctlJSON(w, m.status())
+			return
+		}
+		lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(tailLines(ctl.logs[name], lines))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		ctlError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var err error
+	switch action {
+	case "start":
+		err = ctl.g.StartMember(name)
+	case "stop":
+		err = ctl.g.StopMember(name)
+	case "restart":
+		err = ctl.g.RestartMember(name)
+	case "signal":
+		var sig syscall.Signal
+		if sig, err = ParseSignal(r.URL.Query().Get("sig")); err == nil {
+			err = ctl.g.Signal(name, sig)
+		}
+	default:
+		ctlError(w, http.StatusNotFound, fmt.Errorf("unknown action %s", action))
+		return
+	}
+
+	if err != nil {
+		ctlError(w, http.StatusConflict, err)
+		return
+	}
+	ctlJSON(w, m.status())
+}
+
+// 解析信号，支持 HUP、SIGHUP 和数字
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// 最后 n 行，缓存开头被丢弃时去掉不完整的第一行
+func tailLines(buf *Buffer, n int) []byte {
+	if n <= 0 {
+		n = 100
+	}
+	data := buf.Bytes()
+	if buf.Dropped() > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	end := len(bytes.TrimSuffix(data, []byte{'\n'}))
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			if n--; n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+type ctlErrorBody struct {
+	Error string `json:"error"`
+}
+
+func ctlJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func ctlError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ctlErrorBody{Error: err.Error()})
+}
+
+// 控制接口的客户端
+func DialControl(path string) *ControlClient {
+	return &ControlClient{
+		c: &http.Client{
+			Timeout: time.Minute,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+type ControlClient struct {
+	c *http.Client
+}
+
+// 所有成员的状态
+func (c *ControlClient) List() (status []MemberStatus, err error) {
+	err = c.do(http.MethodGet, "/processes", &status)
+	return
+}
+
+func (c *ControlClient) Status(name string) (status MemberStatus, err error) {
+	err = c.do(http.MethodGet, "/processes/"+name, &status)
+	return
+}
+
+func (c *ControlClient) Start(name string) (status MemberStatus, err error) {
+	err = c.do(http.MethodPost, "/processes/"+name+"/start", &status)
+	return
+}
+
+func (c *ControlClient) Stop(name string) (status MemberStatus, err error) {
+	err = c.do(http.MethodPost, "/processes/"+name+"/stop", &status)
+	return
+}
+
+func (c *ControlClient) Restart(name string) (status MemberStatus, err error) {
+	err = c.do(http.MethodPost, "/processes/"+name+"/restart", &status)
+	return
+}
+
+// 发送信号，sig 如 HUP、SIGUSR1、15
+func (c *ControlClient) Signal(name, sig string) (status MemberStatus, err error) {
+	err = c.do(http.MethodPost, "/processes/"+name+"/signal?sig="+sig, &status)
+	return
+}
+
+// 最近 lines 行输出
+func (c *ControlClient) Logs(name string, lines int) ([]byte, error) {
+	var out []byte
+	err := c.do(http.MethodGet, "/processes/"+name+"/logs?lines="+strconv.Itoa(lines), &out)
+	return out, err
+}
+
+func (c *ControlClient) do(method, path string, out any) error {
+	req, err := http.NewRequest(method, "http://control"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e ctlErrorBody
+		if json.Unmarshal(buf.Bytes(), &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("control: %s", resp.Status)
+	}
+
+	if b, ok := out.(*[]byte); ok {
+		*b = buf.Bytes()
+		return nil
+	}
+	return json.Unmarshal(buf.Bytes(), out)
+}
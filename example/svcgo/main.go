@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/cnk3x/cmd"
@@ -16,12 +17,17 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func flagParse() (configFn, command string) {
+func flagParse() (configFn, command string, args []string) {
 	flag.Usage = func() {
 		name := filepath.Base(os.Args[0])
 		fmt.Fprintf(os.Stderr, "测试运行  %s -c path/to/config.yaml           \n", name)
 		fmt.Fprintf(os.Stderr, "安装服务  %s -c path/to/config.yaml install   \n", name)
 		fmt.Fprintf(os.Stderr, "卸载服务  %s -c path/to/config.yaml uninstall \n", name)
+		fmt.Fprintf(os.Stderr, "进程列表  %s -c path/to/config.yaml ctl list      \n", name)
+		fmt.Fprintf(os.Stderr, "进程状态  %s -c path/to/config.yaml ctl status    \n", name)
+		fmt.Fprintf(os.Stderr, "启停进程  %s -c path/to/config.yaml ctl start|stop|restart \n", name)
+		fmt.Fprintf(os.Stderr, "发送信号  %s -c path/to/config.yaml ctl signal HUP \n", name)
+		fmt.Fprintf(os.Stderr, "最近输出  %s -c path/to/config.yaml ctl logs [100] \n", name)
 	}
 
 	var cwd, _ = os.Getwd()
@@ -30,6 +36,9 @@ func flagParse() (configFn, command string) {
 	flag.Parse()
 
 	command = flag.Arg(0)
+	if flag.NArg() > 1 {
+		args = flag.Args()[1:]
+	}
 	configFn, _ = filepath.Abs(configFn)
 	if !strings.HasSuffix(configFn, ".yaml") {
 		configFn += ".yaml"
@@ -38,7 +47,7 @@ func flagParse() (configFn, command string) {
 }
 
 func main() {
-	configFn, command := flagParse()
+	configFn, command, args := flagParse()
 
 	workDir, name := filepath.Split(configFn)
 	name = strings.TrimSuffix(name, filepath.Ext(name))
@@ -71,6 +80,22 @@ func main() {
 
 	cfg.name = cfg.Name
 	cfg.workDir = workDir
+	if cfg.Control == "" {
+		cfg.Control = "{base}/{name}.sock"
+	}
+	cfg.Control = cfg.resolvePath(cfg.Control)
+
+	//通过控制接口操作运行中的服务，服务本身的启停仍使用 start/stop
+	if command == "ctl" {
+		if err := control(cfg.CommandOptions, args); err != nil {
+			if err == svc.ErrHelp {
+				flag.Usage()
+				os.Exit(2)
+			}
+			log.Fatalln(err)
+		}
+		return
+	}
 
 	runner := createRunner(cfg.CommandOptions)
 	man := kardianos.New(runner, cfg.Config, "-c", configFn, "run")
@@ -106,23 +131,79 @@ func writeYaml(fn string, value any) error {
 	return os.WriteFile(fn, data, 0666)
 }
 
-func createRunner(cfg CommandOptions) svc.ServiceRunner {
-	return func(ctx context.Context) (done <-chan struct{}, err error) {
-		repl := func(s string) string {
-			return fasttemplate.ExecuteString(s, "{", "}", map[string]any{"base": cfg.workDir, "name": cfg.name})
-		}
+// 控制接口的子命令
+func control(cfg CommandOptions, args []string) (err error) {
+	if len(args) == 0 {
+		return svc.ErrHelp
+	}
+	client := cmd.DialControl(cfg.Control)
+	command, args := args[0], args[1:]
 
-		resolvePath := func(path string) string {
-			if path = repl(path); path != "" && !filepath.IsAbs(path) {
-				path = filepath.Join(cfg.workDir, path)
+	var status cmd.MemberStatus
+	switch command {
+	case "list":
+		var list []cmd.MemberStatus
+		if list, err = client.List(); err == nil {
+			for _, s := range list {
+				printStatus(s)
+			}
+		}
+		return err
+	case "status":
+		status, err = client.Status(cfg.name)
+	case "start":
+		status, err = client.Start(cfg.name)
+	case "stop":
+		status, err = client.Stop(cfg.name)
+	case "restart":
+		status, err = client.Restart(cfg.name)
+	case "signal":
+		if len(args) == 0 {
+			return svc.ErrHelp
+		}
+		status, err = client.Signal(cfg.name, args[0])
+	case "logs":
+		lines := 100
+		if len(args) > 0 {
+			if lines, err = strconv.Atoi(args[0]); err != nil {
+				return err
 			}
-			return path
 		}
+		var out []byte
+		if out, err = client.Logs(cfg.name, lines); err == nil {
+			os.Stdout.Write(out)
+		}
+		return err
+	default:
+		return svc.ErrHelp
+	}
 
-		c := cmd.CommandLine(repl(cfg.Command)).With(cmd.WorkDir(cfg.workDir))
+	if err == nil {
+		printStatus(status)
+	}
+	return err
+}
 
-		cfg.Logger.Path = resolvePath(cfg.Logger.Path)
+func printStatus(s cmd.MemberStatus) {
+	fmt.Printf("%-16s %-10s pid=%-8d restarts=%-4d %s\n", s.Name, s.Status, s.PID, s.Restarts, s.Error)
+}
+
+func createRunner(cfg CommandOptions) svc.ServiceRunner {
+	return func(ctx context.Context) (done <-chan struct{}, err error) {
+		c := cmd.CommandLine(cfg.repl(cfg.Command)).With(cmd.WorkDir(cfg.workDir))
+		if len(cfg.Limits) > 0 {
+			c.With(cmd.Limits(cfg.Limits))
+		}
+		if cfg.Cgroup != nil {
+			c.Cgroup(*cfg.Cgroup)
+		}
+		if cfg.TreeKill {
+			c.TreeKill()
+		}
+
+		cfg.Logger.Path = cfg.resolvePath(cfg.Logger.Path)
 		c.Logger(cfg.Logger)
+		c.Ready(cfg.Ready).Health(cfg.Health)
 
 		c.PreExit(func(c *cmd.Cmd) {
 			for _, n := range cfg.BeforeExit {
@@ -136,9 +217,53 @@ func createRunner(cfg CommandOptions) svc.ServiceRunner {
 			}
 		})
 
-		state := c.Run()
-		return state.Done(), state.Err
+		g := cmd.NewGroup().Add(cfg.name, c, cfg.Restart)
+		ctl := cmd.NewControl(g)
+		if err = g.RunWithContext(ctx); err != nil {
+			return
+		}
+
+		//第一次启动失败时返回错误，由服务管理器处理
+		state, err := g.Member(cfg.name).WaitStarted(ctx)
+		if err == nil && state.PID == 0 {
+			err = state.Err
+		}
+		if err != nil {
+			g.Stop()
+			return nil, err
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			if err := ctl.Serve(ctx, cfg.Control); err != nil {
+				log.Println("control:", err)
+			}
+		}()
+
+		if cfg.Metrics != "" {
+			go func() {
+				if err := cmd.NewMetrics(g).Serve(ctx, cfg.Metrics); err != nil {
+					log.Println("metrics:", err)
+				}
+			}()
+		}
+
+		//SIGHUP/SIGUSR1 时重新打开日志，配合 logrotate 使用
+		stopReopen := cmd.ReopenOnSignal()
+		go func() { <-g.Done(); stopReopen(); cancel() }()
+		return g.Done(), nil
+	}
+}
+
+func (cfg CommandOptions) repl(s string) string {
+	return fasttemplate.ExecuteString(s, "{", "}", map[string]any{"base": cfg.workDir, "name": cfg.name})
+}
+
+func (cfg CommandOptions) resolvePath(path string) string {
+	if path = cfg.repl(path); path != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.workDir, path)
 	}
+	return path
 }
 
 type Config struct {
@@ -150,8 +275,16 @@ type CommandOptions struct {
 	name    string //服务名称
 	workDir string //工作目录，从服务配置转存过来
 
-	Command      string            `json:"command,omitempty" yaml:"command,omitempty"`
-	Logger       cmd.LoggerOptions `json:"logger,omitempty" yaml:"logger,omitempty"`
-	AfterStarted []string          `json:"after_started,omitempty" yaml:"after_started,omitempty"`
-	BeforeExit   []string          `json:"before_exit,omitempty" yaml:"before_exit,omitempty"`
+	Command      string             `json:"command,omitempty" yaml:"command,omitempty"`
+	Control      string             `json:"control,omitempty" yaml:"control,omitempty"` //控制接口的 Unix 套接字，默认 {base}/{name}.sock
+	Metrics      string             `json:"metrics,omitempty" yaml:"metrics,omitempty"` //Prometheus 指标的监听地址，如 127.0.0.1:9100，为空不启用
+	Logger       cmd.LoggerOptions  `json:"logger,omitempty" yaml:"logger,omitempty"`
+	Restart      cmd.RestartOptions `json:"restart,omitempty" yaml:"restart,omitempty"`
+	Limits       map[string]uint64  `json:"limits,omitempty" yaml:"limits,omitempty"`       //资源限制，如 {nofile: 65536}
+	Cgroup       *cmd.CgroupOptions `json:"cgroup,omitempty" yaml:"cgroup,omitempty"`       //放入独立的 cgroup v2 并限制资源
+	TreeKill     bool               `json:"tree_kill,omitempty" yaml:"tree_kill,omitempty"` //停止时结束整个进程树，包括离开进程组的子孙进程
+	Ready        cmd.ReadyOptions   `json:"ready,omitempty" yaml:"ready,omitempty"`         //就绪后才执行 after_started
+	Health       cmd.HealthOptions  `json:"health,omitempty" yaml:"health,omitempty"`
+	AfterStarted []string           `json:"after_started,omitempty" yaml:"after_started,omitempty"`
+	BeforeExit   []string           `json:"before_exit,omitempty" yaml:"before_exit,omitempty"`
 }
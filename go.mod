@@ -1,3 +1,5 @@
 module github.com/cnk3x/cmd
 
 go 1.21.1
+
+require github.com/klauspost/compress v1.17.11
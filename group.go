@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+)
+
+// 进程组，多个命令作为一个整体按依赖顺序启动，按相反顺序停止
+func NewGroup() *Group {
+	return &Group{index: map[string]*groupMember{}}
+}
+
+type Group struct {
+	members    []*groupMember
+	index      map[string]*groupMember
+	stopOnExit bool
+
+	mu       sync.Mutex
+	order    []*groupMember //启动顺序
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopping bool
+}
+
+type groupMember struct {
+	name  string
+	cmd   *Cmd
+	after []string
+	sup   *Supervisor
+	held  bool //被单独停止，退出时不影响整个组
+}
+
+// 成员状态
+type MemberStatus struct {
+	Name     string          `json:"name"`
+	Command  string          `json:"command"`
+	Status   SuperviseStatus `json:"status"`
+	PID      int             `json:"pid,omitempty"`
+	Restarts int             `json:"restarts"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// 添加成员，after 中的成员就绪后才启动它
+func (g *Group) Add(name string, c *Cmd, restart RestartOptions, after ...string) *Group {
+	if c.name == "" {
+		c.name = name
+	}
+	m := &groupMember{name: name, cmd: c, after: after, sup: Supervise(c, restart)}
+	g.members = append(g.members, m)
+	g.index[name] = m
+	return g
+}
+
+// 任一成员退出(且不再重启)时停止整个组
+func (g *Group) StopOnExit() *Group {
+	g.stopOnExit = true
+	return g
+}
+
+func (g *Group) Run() error {
+	return g.RunWithContext(context.Background())
+}
+
+// 按依赖顺序启动所有成员，依赖未能就绪时停止已启动的成员并返回错误；ctx 结束时停止整个组
+func (g *Group) RunWithContext(ctx context.Context) error {
+	order, err := g.sort()
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	if g.done != nil {
+		g.mu.Unlock()
+		return errors.New("group already started")
+	}
+	g.order = order
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	g.done = make(chan struct{})
+	g.mu.Unlock()
+
+	go func() {
+		<-g.ctx.Done()
+		g.Stop()
+	}()
+
+	for _, m := range order {
+		for _, dep := range m.after {
+			if err := g.index[dep].sup.waitReady(g.ctx); err != nil {
+				g.Stop()
+				return fmt.Errorf("%s: dependency %s: %w", m.name, dep, err)
+			}
+		}
+		g.start(m)
+	}
+	return nil
+}
+
+// 启动成员并等待其结束，已在运行时不做任何事
+func (g *Group) start(m *groupMember) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if done := m.sup.Done(); done != nil && !isClosed(done) {
+		return
+	}
+	m.held = false
+
+	done := m.sup.RunWithContext(g.ctx).Done()
+	go func() {
+		<-done
+		g.exited(m, done)
+	}()
+}
+
+// 成员结束，未被单独停止的成员全部结束时关闭 Done；被单独停止的成员不触发 StopOnExit，
+// 也不会结束整个组(之后还可以 StartMember)，已重新启动的成员忽略
+func (g *Group) exited(m *groupMember, done <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if m.sup.Done() != done || m.held {
+		return
+	}
+
+	if g.stopOnExit && !g.stopping {
+		go g.Stop()
+		return
+	}
+
+	for _, m := range g.members {
+		if done := m.sup.Done(); done == nil || !isClosed(done) {
+			return
+		}
+	}
+	if !isClosed(g.done) {
+		close(g.done)
+		g.cancel()
+	}
+}
+
+// 启动单个成员并等待进程启动，已在运行时不做任何事
+func (g *Group) StartMember(name string) error {
+	m, err := g.running(name)
+	if err != nil {
+		return err
+	}
+	g.start(m)
+	_, err = m.sup.WaitStarted(g.ctx)
+	return err
+}
+
+// 停止单个成员，不影响组内其他成员，也不会触发 StopOnExit 或结束整个组，之后可以再 StartMember
+func (g *Group) StopMember(name string) error {
+	m, err := g.running(name)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	m.held = true
+	g.mu.Unlock()
+
+	m.sup.Stop()
+	return nil
+}
+
+// 停止并重新启动单个成员，重启计数不清零
+func (g *Group) RestartMember(name string) error {
+	if err := g.StopMember(name); err != nil {
+		return err
+	}
+	return g.StartMember(name)
+}
+
+// 向成员当前的进程发送信号
+func (g *Group) Signal(name string, sig syscall.Signal) error {
+	m, err := g.running(name)
+	if err != nil {
+		return err
+	}
+	return m.sup.Signal(sig)
+}
+
+// 运行中的组的成员
+func (g *Group) running(name string) (*groupMember, error) {
+	m := g.index[name]
+	if m == nil {
+		return nil, fmt.Errorf("unknown member %s", name)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done == nil || g.stopping || isClosed(g.done) {
+		return nil, errors.New("group not running")
+	}
+	return m, nil
+}
+
+// 按启动的相反顺序逐个停止，每个成员使用其 Cmd 的停止策略
+func (g *Group) Stop() {
+	g.mu.Lock()
+	if g.done == nil || g.stopping || isClosed(g.done) {
+		g.mu.Unlock()
+		return
+	}
+	g.stopping = true
+	order := g.order
+	g.mu.Unlock()
+
+	for i := len(order) - 1; i >= 0; i-- {
+		order[i].sup.Stop()
+	}
+	g.cancel()
+	g.close()
+}
+
+func (g *Group) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !isClosed(g.done) {
+		close(g.done)
+	}
+}
+
+// 所有成员都结束后关闭
+func (g *Group) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done
+}
+
+func (g *Group) Wait() error {
+	if done := g.Done(); done != nil {
+		<-done
+	}
+
+	//被停止的成员不算错误
+	var errs []error
+	for _, m := range g.members {
+		if err := m.sup.Err(); err != nil && m.sup.Status() != SuperviseStopped {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 按添加顺序返回所有成员的状态
+func (g *Group) Status() []MemberStatus {
+	status := make([]MemberStatus, len(g.members))
+	for i, m := range g.members {
+		status[i] = m.status()
+	}
+	return status
+}
+
+// 成员的守护进程，不存在时返回 nil
+func (g *Group) Member(name string) *Supervisor {
+	if m := g.index[name]; m != nil {
+		return m.sup
+	}
+	return nil
+}
+
+// 成员名称，按添加顺序
+func (g *Group) Names() []string {
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.name
+	}
+	return names
+}
+
+func (m *groupMember) status() MemberStatus {
+	s := MemberStatus{
+		Name:     m.name,
+		Command:  m.cmd.String(),
+		Status:   m.sup.Status(),
+		Restarts: m.sup.Restarts(),
+	}
+	if state := m.sup.State(); state != nil && s.Status == SuperviseRunning {
+		s.PID = state.PID
+	}
+	if err := m.sup.Err(); err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// 按依赖关系排序，依赖不存在或循环依赖时返回错误
+func (g *Group) sort() (order []*groupMember, err error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := map[string]int{}
+
+	var visit func(m *groupMember, path []string) error
+	visit = func(m *groupMember, path []string) error {
+		switch marks[m.name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v", append(path, m.name))
+		}
+		marks[m.name] = visiting
+		for _, dep := range m.after {
+			d := g.index[dep]
+			if d == nil {
+				return fmt.Errorf("%s: unknown dependency %s", m.name, dep)
+			}
+			if err := visit(d, append(path, m.name)); err != nil {
+				return err
+			}
+		}
+		marks[m.name] = visited
+		order = append(order, m)
+		return nil
+	}
+
+	for _, m := range g.members {
+		if err = visit(m, nil); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
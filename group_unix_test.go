@@ -0,0 +1,164 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录成员的启动和停止顺序
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) cmd(name, command string) *Cmd {
+	return Shell(command).OnStatus(func(_ *StartState, t Transition) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		switch t.To {
+		case StatusStarted:
+			l.events = append(l.events, "start "+name)
+		case StatusStopped:
+			l.events = append(l.events, "stop "+name)
+		}
+	})
+}
+
+func (l *eventLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.events, ",")
+}
+
+func TestGroupOrder(t *testing.T) {
+	var log eventLog
+	g := NewGroup().
+		Add("c", log.cmd("c", "sleep 10"), RestartOptions{}, "b").
+		Add("a", log.cmd("a", "sleep 10"), RestartOptions{}).
+		Add("b", log.cmd("b", "sleep 10"), RestartOptions{}, "a")
+
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	g.Stop()
+
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.String(); got != "start a,start b,start c,stop c,stop b,stop a" {
+		t.Fatalf("events: %s", got)
+	}
+}
+
+func TestGroupDependencyErrors(t *testing.T) {
+	g := NewGroup().
+		Add("a", New("true"), RestartOptions{}, "b").
+		Add("b", New("true"), RestartOptions{}, "a")
+	if err := g.Run(); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("cycle: %v", err)
+	}
+
+	g = NewGroup().Add("a", New("true"), RestartOptions{}, "x")
+	if err := g.Run(); err == nil || !strings.Contains(err.Error(), "unknown dependency") {
+		t.Fatalf("unknown: %v", err)
+	}
+}
+
+func TestGroupStopOnExit(t *testing.T) {
+	var log eventLog
+	g := NewGroup().StopOnExit().
+		Add("a", log.cmd("a", "sleep 10"), RestartOptions{}).
+		Add("b", log.cmd("b", "sleep 0.1; exit 3"), RestartOptions{}, "a")
+
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-g.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("group not stopped")
+	}
+
+	if err := g.Wait(); err == nil || !strings.Contains(err.Error(), "b: ") {
+		t.Fatalf("err: %v", err)
+	}
+	if got := log.String(); got != "start a,start b,stop b,stop a" {
+		t.Fatalf("events: %s", got)
+	}
+}
+
+func TestGroupStopMember(t *testing.T) {
+	g := NewGroup().StopOnExit().
+		Add("a", Shell("sleep 10"), RestartOptions{}).
+		Add("b", Shell("sleep 0.2"), RestartOptions{})
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	//已在运行时不会重复启动
+	state, _ := g.Member("a").WaitStarted(context.Background())
+	pid := state.PID
+	if err := g.StartMember("a"); err != nil || g.Member("a").State().PID != pid {
+		t.Fatalf("start running member: %v", err)
+	}
+
+	//被单独停止的成员不触发 StopOnExit
+	if err := g.StopMember("a"); err != nil {
+		t.Fatal(err)
+	}
+	if isClosed(g.Done()) {
+		t.Fatal("group stopped by StopMember")
+	}
+
+	//其他成员结束后整个组结束
+	select {
+	case <-g.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("group not done after all members exited")
+	}
+}
+
+// 单个成员的组：单独停止后组仍在运行，可以再启动或重启
+func TestGroupSingleMember(t *testing.T) {
+	g := NewGroup().Add("a", Shell("sleep 10"), RestartOptions{})
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+	state, _ := g.Member("a").WaitStarted(context.Background())
+
+	if err := g.RestartMember("a"); err != nil {
+		t.Fatal(err)
+	}
+	if s := g.Member("a"); s.Status() != SuperviseRunning || s.State().PID == state.PID {
+		t.Fatalf("after restart: %s pid %d", s.Status(), s.State().PID)
+	}
+
+	if err := g.StopMember("a"); err != nil {
+		t.Fatal(err)
+	}
+	<-g.Member("a").Done()
+	time.Sleep(time.Millisecond * 50)
+	if isClosed(g.Done()) {
+		t.Fatal("group stopped by StopMember")
+	}
+	if err := g.StartMember("a"); err != nil {
+		t.Fatal(err)
+	}
+	if s := g.Member("a"); s.Status() != SuperviseRunning {
+		t.Fatalf("after start: %s", s.Status())
+	}
+
+	g.Stop()
+	select {
+	case <-g.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("group not done after Stop")
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 存活检查，进程就绪后周期执行，连续失败达到阈值时结束进程，原因记录在 StartState.Err
+type HealthOptions struct {
+	TCP  string                                             `json:"tcp,omitempty" yaml:"tcp,omitempty"`   //端口可连接, host:port
+	HTTP string                                             `json:"http,omitempty" yaml:"http,omitempty"` //GET 返回 2xx
+	Exec string                                             `json:"exec,omitempty" yaml:"exec,omitempty"` //通过 Shell 执行，退出码为0
+	Func func(ctx context.Context, state *StartState) error `json:"-" yaml:"-"`
+
+	Interval  time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`   //检查间隔，默认10s
+	Timeout   time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`     //单次检查超时，默认5s
+	Threshold int           `json:"threshold,omitempty" yaml:"threshold,omitempty"` //连续失败次数，默认3
+}
+
+// 设置存活检查，配合 Supervisor 可在进程卡死时重启
+func (c *Cmd) Health(options HealthOptions) *Cmd {
+	c.health = options
+	return c
+}
+
+func (o HealthOptions) empty() bool {
+	return o.TCP == "" && o.HTTP == "" && o.Exec == "" && o.Func == nil
+}
+
+// 周期检查直到 ctx 结束或连续失败达到阈值
+func (o HealthOptions) watch(ctx context.Context, state *StartState) func() {
+	if o.Interval <= 0 {
+		o.Interval = time.Second * 10
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second * 5
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 3
+	}
+
+	return func() {
+		if o.empty() {
+			return
+		}
+
+		for failures := 0; ; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(o.Interval):
+			}
+
+			if err := o.check(ctx, state); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if failures++; failures >= o.Threshold {
+					state.fail(fmt.Errorf("unhealthy after %d consecutive failures: %w", failures, err))
+					return
+				}
+			} else {
+				failures = 0
+			}
+		}
+	}
+}
+
+func (o HealthOptions) check(ctx context.Context, state *StartState) error {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	if o.TCP != "" {
+		if err := checkTCP(ctx, o.TCP); err != nil {
+			return err
+		}
+	}
+	if o.HTTP != "" {
+		if err := checkHTTP(ctx, o.HTTP); err != nil {
+			return err
+		}
+	}
+	if o.Exec != "" {
+		if err := Shell(o.Exec).RunWithContext(ctx).Wait(); err != nil {
+			return fmt.Errorf("%s: %w", o.Exec, err)
+		}
+	}
+	if o.Func != nil {
+		return o.Func(ctx, state)
+	}
+	return nil
+}
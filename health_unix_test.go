@@ -0,0 +1,36 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	for _, c := range []struct {
+		exec string
+		err  string //为空时应一直运行
+	}{
+		{"true", ""},
+		{"exit 1", "unhealthy after 3 consecutive failures"},
+	} {
+		s := Shell("sleep 10").Health(HealthOptions{Exec: c.exec, Interval: time.Millisecond * 20, Threshold: 3}).Run()
+
+		select {
+		case <-s.Done():
+		case <-time.After(time.Millisecond * 500):
+		}
+		if c.err == "" {
+			if isClosed(s.Done()) {
+				t.Errorf("%q: exited: %v", c.exec, s.Err)
+			}
+			s.Stop()
+			continue
+		}
+		if err := s.Wait(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q: got %v, want %q", c.exec, err, c.err)
+		}
+	}
+}
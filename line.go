@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const maxLineSize = 64 << 10
+
+// 输出流
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+)
+
+// 一行输出
+type LineEvent struct {
+	Stream Stream    `json:"stream"`
+	Line   string    `json:"line"`
+	Time   time.Time `json:"time"` //读取到该行的时间
+	Seq    uint64    `json:"seq"`  //本次运行中 stdout 和 stderr 共用的序号，从1开始
+
+	Truncated bool `json:"truncated,omitempty"` //超长被截断，或拆分后不是最后一段
+}
+
+// 设置按行读取的最大长度(默认64K)，超长的行 truncate 时丢弃超出部分，否则拆分成多行
+func (c *Cmd) LineLimit(max int, truncate bool) *Cmd {
+	c.lineMax, c.lineTruncate = max, truncate
+	return c
+}
+
+// 按行读取直到 EOF，最后不完整的行也会输出
+func readLines(r io.Reader, max int, truncate bool, emit func(line string, truncated bool)) error {
+	w := newLineWriter(max, truncate, emit)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		w.Write(buf[:n])
+		if err != nil {
+			w.Flush()
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+	}
+}
+
+// 按行回调的 Writer，\n、\r\n 和单独的 \r(进度条) 都作为换行，未满一行的数据缓存到下次写入；
+// 超过 max(默认64K)的行 truncate 时丢弃超出部分，否则拆分成多行
+type lineWriter struct {
+	mu       sync.Mutex
+	max      int
+	truncate bool
+	emit     func(line string, truncated bool)
+
+	line []byte
+	cut  bool //当前行已截断，丢弃后续内容直到换行
+	cr   bool //上一个字节是 \r
+}
+
+func newLineWriter(max int, truncate bool, emit func(line string, truncated bool)) *lineWriter {
+	if max <= 0 {
+		max = maxLineSize
+	}
+	return &lineWriter{max: max, truncate: truncate, emit: emit}
+}
+
+func (w *lineWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range p {
+		switch {
+		case b == '\n' && w.cr: //\r\n 已在 \r 时输出
+			w.cr = false
+		case b == '\n' || b == '\r':
+			w.cr = b == '\r'
+			w.flush()
+		default:
+			w.cr = false
+			if len(w.line) >= w.max {
+				if w.truncate {
+					w.cut = true
+					continue
+				}
+				w.emit(string(w.line), true)
+				w.line = w.line[:0]
+			}
+			w.line = append(w.line, b)
+		}
+	}
+	return len(p), nil
+}
+
+// 输出缓存中不完整的行
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.line) > 0 || w.cut {
+		w.flush()
+	}
+	w.cr = false
+}
+
+func (w *lineWriter) flush() {
+	w.emit(string(w.line), w.cut)
+	w.line, w.cut = w.line[:0], false
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	for _, c := range []struct {
+		in       string
+		max      int
+		truncate bool
+		want     []string
+	}{
+		{"a\nb\r\nc", 0, false, []string{"a", "b", "c"}},
+		{"10%\r20%\r\ndone\n", 0, false, []string{"10%", "20%", "done"}},
+		{"abcdefg\nhi\n", 3, false, []string{"abc+", "def+", "g", "hi"}},
+		{"abcdefg\nhi\n", 3, true, []string{"abc+", "hi"}},
+		{"\n\n", 0, false, []string{"", ""}},
+	} {
+		var got []string
+		readLines(strings.NewReader(c.in), c.max, c.truncate, func(line string, truncated bool) {
+			if truncated {
+				line += "+"
+			}
+			got = append(got, line)
+		})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestLineWriterChunks(t *testing.T) {
+	var got []string
+	w := newLineWriter(4, false, func(line string, truncated bool) {
+		if truncated {
+			line += "+"
+		}
+		got = append(got, line)
+	})
+	for _, p := range []string{"ab", "c\r", "\nde", "fghi\r", "x"} {
+		w.Write([]byte(p))
+	}
+	w.Flush()
+
+	want := []string{"abc", "defg+", "hi", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Prometheus 文本格式的指标，按成员名称区分
+func NewMetrics(g *Group) *Metrics {
+	return &Metrics{g: g}
+}
+
+type Metrics struct {
+	g *Group
+}
+
+// 在 addr 上提供 /metrics，ctx 结束时关闭
+func (m *Metrics) Serve(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err = srv.Serve(ln); errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(m.Gather())
+}
+
+type metric struct {
+	name, kind, help string
+	values           bytes.Buffer
+}
+
+func (mt *metric) add(member string, v float64) {
+	fmt.Fprintf(&mt.values, "%s{name=\"%s\"} %g\n", mt.name, escapeLabel(member), v)
+}
+
+// 所有成员的指标
+func (m *Metrics) Gather() []byte {
+	var (
+		up       = &metric{name: "cmd_up", kind: "gauge", help: "Whether the process is running."}
+		restarts = &metric{name: "cmd_restarts_total", kind: "counter", help: "Number of restarts."}
+		exitCode = &metric{name: "cmd_last_exit_code", kind: "gauge", help: "Exit code of the last run, -1 when killed by a signal."}
+		uptime   = &metric{name: "cmd_uptime_seconds", kind: "gauge", help: "Seconds since the current process started."}
+		ready    = &metric{name: "cmd_ready_latency_seconds", kind: "gauge", help: "Seconds from start to ready of the current process."}
+		cpu      = &metric{name: "cmd_cpu_seconds_total", kind: "counter", help: "User and system CPU time of the current process."}
+		rss      = &metric{name: "cmd_resident_memory_bytes", kind: "gauge", help: "Resident memory size of the current process."}
+		logBytes = &metric{name: "cmd_log_bytes_total", kind: "counter", help: "Bytes written to the rotated log files."}
+	)
+
+	now := time.Now()
+	for _, member := range m.g.members {
+		name, sup := member.name, member.sup
+		restarts.add(name, float64(sup.Restarts()))
+		logBytes.add(name, float64(member.cmd.LogBytes()))
+		if r := sup.LastExit(); r != nil {
+			exitCode.add(name, float64(r.Code))
+		}
+
+		state := sup.State()
+		if state == nil || state.PID == 0 || isClosed(state.Done()) {
+			up.add(name, 0)
+			continue
+		}
+		up.add(name, 1)
+
+		started := state.Since(StatusStarted)
+		if !started.IsZero() {
+			uptime.add(name, now.Sub(started).Seconds())
+		}
+		if readyAt := state.ReadyAt(); !readyAt.IsZero() && !started.IsZero() {
+			ready.add(name, readyAt.Sub(started).Seconds())
+		}
+		if p, err := readProc(state.PID); err == nil {
+			cpu.add(name, p.CPU.Seconds())
+			rss.add(name, float64(p.RSS))
+		}
+	}
+
+	var out bytes.Buffer
+	for _, mt := range []*metric{up, restarts, exitCode, uptime, ready, cpu, rss, logBytes} {
+		if mt.values.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", mt.name, mt.help, mt.name, mt.kind)
+		out.Write(mt.values.Bytes())
+	}
+	return out.Bytes()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	g := NewGroup().
+		Add(`we"ird\name`, Shell("sleep 10"), RestartOptions{}).
+		Add("done", Shell("exit 3"), RestartOptions{})
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	g.Member(`we"ird\name`).WaitStarted(context.Background())
+	<-g.Member("done").Done()
+
+	rec := httptest.NewRecorder()
+	NewMetrics(g).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type: %s", ct)
+	}
+	body := rec.Body.String()
+
+	var (
+		sample = regexp.MustCompile(`^([a-z_]+)\{name="(?:[^"\\\n]|\\[\\"n])*"\} -?[0-9.e+-]+$`)
+		family string
+		seen   = map[string]bool{}
+	)
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if name, ok := strings.CutPrefix(line, "# HELP "); ok {
+			family, _, _ = strings.Cut(name, " ")
+			if seen[family] {
+				t.Errorf("family %s repeated", family)
+			}
+			seen[family] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# TYPE "+family+" ") {
+			continue
+		}
+		m := sample.FindStringSubmatch(line)
+		if m == nil || m[1] != family {
+			t.Errorf("invalid line in %s: %q", family, line)
+		}
+	}
+
+	for _, want := range []string{
+		`cmd_up{name="we\"ird\\name"} 1`,
+		`cmd_up{name="done"} 0`,
+		`cmd_last_exit_code{name="done"} 3`,
+		`cmd_restarts_total{name="done"} 0`,
+		"# TYPE cmd_restarts_total counter",
+		"# TYPE cmd_up gauge",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+
+	if uptime := regexp.MustCompile(`cmd_uptime_seconds\{name="done"\}`); uptime.MatchString(body) {
+		t.Error("uptime reported for exited member")
+	}
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
-	"bufio"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type Option interface{ Apply(cmd *exec.Cmd) error }
@@ -21,10 +24,64 @@ func (options Options) Apply(cmd *exec.Cmd) {
 		if cmd.Err != nil {
 			return
 		}
-		option.Apply(cmd)
+		if err := option.Apply(cmd); err != nil {
+			cmd.Err = err
+		}
+	}
+}
+
+// 应用到本次运行，需要运行状态的选项在 r 中登记
+func (options Options) prepare(cmd *exec.Cmd, r *run) {
+	for _, option := range options {
+		if cmd.Err != nil {
+			return
+		}
+		var err error
+		if f, ok := option.(runOption); ok {
+			err = f(cmd, r)
+		} else {
+			err = option.Apply(cmd)
+		}
+		if err != nil {
+			cmd.Err = err
+		}
+	}
+}
+
+// 单次运行的状态，重启或同一个 Cmd 并发运行时互不影响
+type run struct {
+	starting  []func(cmd *exec.Cmd) error          //所有选项应用后、启动前执行，可改写最终的命令和环境变量
+	started   []func(pid int) error                //进程启动后立即执行
+	signalers []func(pgid int, sig syscall.Signal) //停止时除进程组外还要发送信号的目标，如 cgroup
+	exited    []func(r *ExitResult)                //进程退出后补充退出结果
+	cleanup   Runner                               //进程结束后执行，启动失败时也会执行，释放本次运行中创建的管道、文件等
+}
+
+// 启动前执行，返回第一个错误
+func (r *run) beforeStart(cmd *exec.Cmd) error {
+	for _, fn := range r.starting {
+		if err := fn(cmd); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
+// 进程启动后执行，返回第一个错误
+func (r *run) start(pid int) error {
+	for _, fn := range r.started {
+		if err := fn(pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 需要单次运行状态的选项，只能由 Cmd 应用
+type runOption func(cmd *exec.Cmd, r *run) error
+
+func (f runOption) Apply(*exec.Cmd) error { return errors.New("option can only be applied by Cmd") }
+
 func User(uid, pid uint32) Option {
 	return FOption(func(c *exec.Cmd) { setUser(c.SysProcAttr, uid, pid) })
 }
@@ -38,7 +95,7 @@ func WorkDir(workDir string) Option {
 }
 
 func (c *Cmd) PreExit(task func(c *Cmd), parallel ...bool) *Cmd {
-	c.postStart.Append(func() { task(c) }, parallel...)
+	c.preExit.Append(func() { task(c) }, parallel...)
 	return c
 }
 
@@ -58,7 +115,7 @@ func (c *Cmd) Logger(options LoggerOptions) *Cmd {
 		options.Path = ""
 	}
 
-	create := func(std io.WriteCloser, path string) io.Writer {
+	create := func(r *run, std io.WriteCloser, path string) io.Writer {
 		var w io.Writer
 		if options.Std {
 			w = std
@@ -66,28 +123,31 @@ func (c *Cmd) Logger(options LoggerOptions) *Cmd {
 
 		if path != "" {
 			options.Path = path
-			rotate := Rotate(options)
+			rotate := newRotateWriter(options)
+			rotate.written = &c.logBytes
+			registerLog(rotate)
 
 			if w != nil {
 				w = io.MultiWriter(w, rotate)
 			} else {
 				w = rotate
 			}
-			c.preExit.Parallel(WrapClose(rotate))
+			r.cleanup.Parallel(func() { unregisterLog(rotate); rotate.Close() })
 		}
 
 		return w
 	}
 
-	return c.With(FOption(func(cmd *exec.Cmd) {
+	return c.With(runOption(func(cmd *exec.Cmd, r *run) error {
 		if options.Path != "" {
 			ext := filepath.Ext(options.Path)
 			outPath := options.Path
 			errPath := strings.TrimSuffix(options.Path, ext) + "-err" + ext
 
-			cmd.Stdout = create(os.Stdout, outPath)
-			cmd.Stderr = create(os.Stderr, errPath)
+			cmd.Stdout = create(r, os.Stdout, outPath)
+			cmd.Stderr = create(r, os.Stderr, errPath)
 		}
+		return nil
 	}))
 }
 
@@ -108,10 +168,23 @@ func (c *Cmd) Stderr(w io.WriteCloser) *Cmd {
 func (c *Cmd) Stdout(w io.WriteCloser) *Cmd {
 	c.preExit.Parallel(WrapClose(w))
 	return c.With(FOption(func(cmd *exec.Cmd) {
-		cmd.Stdout = os.Stdout
+		cmd.Stdout = w
+	}))
+}
+
+// 标准输出和标准错误同时写入 w，需在其他设置输出的选项之后调用
+func (c *Cmd) Tee(w io.Writer) *Cmd {
+	return c.With(FOption(func(cmd *exec.Cmd) {
+		cmd.Stdout = teeWriter(cmd.Stdout, w)
+		cmd.Stderr = teeWriter(cmd.Stderr, w)
 	}))
 }
 
+// Logger 写入日志文件的总字节数，包括之前的运行
+func (c *Cmd) LogBytes() int64 {
+	return c.logBytes.Load()
+}
+
 func (c *Cmd) LoggerWriter(w io.WriteCloser) *Cmd {
 	c.preExit.Append(WrapClose(w))
 	return c.With(FOption(func(cmd *exec.Cmd) {
@@ -120,38 +193,48 @@ func (c *Cmd) LoggerWriter(w io.WriteCloser) *Cmd {
 	}))
 }
 
+// 按行读取输出，flag 为 stdout 或 stderr
 func (c *Cmd) LineRead(lineRd func(flag, line string), transformers ...func(io.Reader) io.Reader) *Cmd {
-	// 按行读取
-	startLineRead := func(std io.Reader, handle func(s string)) func() {
-		return func() {
-			if std != nil && handle != nil {
-				for s := bufio.NewScanner(std); s.Scan(); {
-					handle(s.Text())
-				}
-			}
-		}
-	}
+	return c.OnLine(func(e LineEvent) { lineRd(string(e.Stream), e.Line) }, transformers...)
+}
 
-	return c.With(FOptionEx(func(cmd *exec.Cmd) error {
-		cmd.Stdout = nil
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return err
-		}
+// 按行读取输出，回调按 Seq 顺序串行执行
+func (c *Cmd) OnLine(onLine func(e LineEvent), transformers ...func(io.Reader) io.Reader) *Cmd {
+	// 按行读取，进程结束后关闭管道并等待读取完成
+	startLineRead := func(r *run, handle func(s string, truncated bool)) io.Writer {
+		pr, pw := io.Pipe()
+		var std io.Reader = pr
 		for _, transformer := range transformers {
-			stdout = io.NopCloser(transformer(stdout))
+			std = transformer(std)
 		}
-		c.postStart.Parallel(startLineRead(stdout, func(s string) { lineRd("-", s) }))
 
-		cmd.Stderr = nil
-		stderr, err := cmd.StderrPipe()
-		if err != nil {
-			return err
-		}
-		for _, transformer := range transformers {
-			stderr = io.NopCloser(transformer(stderr))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			readLines(std, c.lineMax, c.lineTruncate, handle)
+			io.Copy(io.Discard, pr) //读取出错时继续排空，避免子进程阻塞
+		}()
+
+		r.cleanup.Append(func() { pw.Close(); <-done })
+		return pw
+	}
+
+	return c.With(runOption(func(cmd *exec.Cmd, r *run) error {
+		var (
+			mu  sync.Mutex
+			seq uint64
+		)
+		emit := func(stream Stream) func(s string, truncated bool) {
+			return func(s string, truncated bool) {
+				mu.Lock()
+				defer mu.Unlock()
+				seq++
+				onLine(LineEvent{Stream: stream, Line: s, Time: time.Now(), Seq: seq, Truncated: truncated})
+			}
 		}
-		c.postStart.Parallel(startLineRead(stderr, func(s string) { lineRd("-", s) }))
+
+		cmd.Stdout = startLineRead(r, emit(Stdout))
+		cmd.Stderr = startLineRead(r, emit(Stderr))
 		return nil
 	}))
 }
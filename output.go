@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// 有上限的输出缓存，超出时只保留开头 head 字节和末尾 tail 字节，都为0时不限制
+type Buffer struct {
+	mu        sync.Mutex
+	headLimit int
+	tailLimit int
+	head      []byte
+	tail      []byte //环形缓存
+	pos       int    //环形缓存下一个写入位置
+	tailSize  int64  //写入环形缓存的总字节数
+	dropped   int64
+}
+
+func NewBuffer(head, tail int) *Buffer {
+	return &Buffer{headLimit: max(head, 0), tailLimit: max(tail, 0)}
+}
+
+func (b *Buffer) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n = len(p)
+	if b.headLimit == 0 && b.tailLimit == 0 {
+		b.head = append(b.head, p...)
+		return
+	}
+
+	if room := b.headLimit - len(b.head); room > 0 {
+		room = min(room, len(p))
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	if len(p) == 0 {
+		return
+	}
+
+	if b.tailLimit == 0 {
+		b.dropped += int64(len(p))
+		return
+	}
+
+	if b.tail == nil {
+		b.tail = make([]byte, b.tailLimit)
+	}
+	b.tailSize += int64(len(p))
+	if len(p) > b.tailLimit {
+		p = p[len(p)-b.tailLimit:]
+	}
+	for len(p) > 0 {
+		c := copy(b.tail[b.pos:], p)
+		b.pos = (b.pos + c) % b.tailLimit
+		p = p[c:]
+	}
+	b.dropped = max(b.tailSize-int64(b.tailLimit), 0)
+	return
+}
+
+// 保留的内容，开头和末尾直接相连
+func (b *Buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := append([]byte(nil), b.head...)
+	if b.tailSize > int64(b.tailLimit) {
+		out = append(out, b.tail[b.pos:]...)
+		return append(out, b.tail[:b.pos]...)
+	}
+	return append(out, b.tail[:b.tailSize]...)
+}
+
+// 被丢弃的字节数
+func (b *Buffer) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+func (b *Buffer) String() string {
+	return string(b.Bytes())
+}
+
+// 设置 Output/CombinedOutput 的缓存上限，只保留开头 head 字节和末尾 tail 字节
+func (c *Cmd) OutputLimit(head, tail int) *Cmd {
+	c.outputHead, c.outputTail = head, tail
+	return c
+}
+
+// 运行到结束并返回标准输出，进程未能启动时 result 为 nil
+func (c *Cmd) Output() (out []byte, result *ExitResult, err error) {
+	return c.OutputWithContext(context.Background())
+}
+
+func (c *Cmd) OutputWithContext(ctx context.Context) (out []byte, result *ExitResult, err error) {
+	return c.capture(ctx, false)
+}
+
+// 运行到结束并返回标准输出和标准错误的合并内容
+func (c *Cmd) CombinedOutput() (out []byte, result *ExitResult, err error) {
+	return c.CombinedOutputWithContext(context.Background())
+}
+
+func (c *Cmd) CombinedOutputWithContext(ctx context.Context) (out []byte, result *ExitResult, err error) {
+	return c.capture(ctx, true)
+}
+
+func (c *Cmd) capture(ctx context.Context, combined bool) (out []byte, result *ExitResult, err error) {
+	buf := NewBuffer(c.outputHead, c.outputTail)
+
+	state := c.run(ctx, FOption(func(cmd *exec.Cmd) {
+		cmd.Stdout = teeWriter(cmd.Stdout, buf)
+		if combined {
+			cmd.Stderr = teeWriter(cmd.Stderr, buf)
+		}
+	}))
+
+	err = state.Wait()
+	return buf.Bytes(), state.Result(), err
+}
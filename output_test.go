@@ -0,0 +1,29 @@
+package cmd
+
+import "testing"
+
+func TestBuffer(t *testing.T) {
+	for _, c := range []struct {
+		head, tail int
+		writes     []string
+		out        string
+		dropped    int64
+	}{
+		{0, 0, []string{"ab", "cd"}, "abcd", 0},
+		{4, 0, []string{"ab", "cdef", "g"}, "abcd", 3},
+		{0, 4, []string{"ab", "cdef", "g"}, "defg", 3},
+		{0, 3, []string{"abc"}, "abc", 0},
+		{3, 4, []string{"ab", "cdefg", "hij"}, "abcghij", 3},
+		{3, 4, []string{"abcde"}, "abcde", 0},
+		{2, 3, []string{"abcdefghij"}, "abhij", 5},
+		{2, 3, []string{"abcdefghij", "k"}, "abijk", 6},
+	} {
+		b := NewBuffer(c.head, c.tail)
+		for _, w := range c.writes {
+			b.Write([]byte(w))
+		}
+		if b.String() != c.out || b.Dropped() != c.dropped {
+			t.Errorf("head %d tail %d %q: got %q dropped %d, want %q dropped %d", c.head, c.tail, c.writes, b.String(), b.Dropped(), c.out, c.dropped)
+		}
+	}
+}
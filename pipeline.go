@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// 管道，前一个命令的标准输出通过系统管道连接到后一个命令的标准输入
+func Pipe(cmds ...*Cmd) *Pipeline {
+	return &Pipeline{cmds: cmds}
+}
+
+type Pipeline struct {
+	cmds        []*Cmd
+	mergeStderr bool
+}
+
+// 除最后一个命令外，标准错误也写入管道，相当于 shell 的 |&
+func (p *Pipeline) MergeStderr() *Pipeline {
+	p.mergeStderr = true
+	return p
+}
+
+func (p *Pipeline) Run() *PipelineState {
+	return p.RunWithContext(context.Background())
+}
+
+// 启动所有命令，ctx 结束或任一命令启动失败时通过 Terminate 结束所有命令
+func (p *Pipeline) RunWithContext(ctx context.Context) *PipelineState {
+	ctx, cancel := context.WithCancel(ctx)
+	state := &PipelineState{cancel: cancel, done: make(chan struct{})}
+
+	var (
+		stdin  *os.File
+		failed bool
+	)
+	for i, c := range p.cmds {
+		var pr, pw *os.File
+		if i < len(p.cmds)-1 {
+			var err error
+			if pr, pw, err = os.Pipe(); err != nil {
+				state.Stages = append(state.Stages, failedState(err))
+				failed = true
+				break
+			}
+		}
+
+		in, merge := stdin, p.mergeStderr
+		s := c.run(ctx, FOption(func(cmd *exec.Cmd) {
+			if in != nil {
+				cmd.Stdin = in
+			}
+			if pw != nil {
+				cmd.Stdout = pw
+				if merge {
+					cmd.Stderr = pw
+				}
+			}
+		}))
+
+		//子进程已持有管道，关闭本进程的副本，前后的命令才能收到 EOF 或 SIGPIPE
+		if stdin != nil {
+			stdin.Close()
+		}
+		if pw != nil {
+			pw.Close()
+		}
+		stdin = pr
+
+		state.Stages = append(state.Stages, s)
+		if failed = s.PID == 0; failed { //启动失败
+			break
+		}
+	}
+
+	if stdin != nil {
+		stdin.Close()
+	}
+
+	if failed {
+		cancel()
+	}
+
+	go func() {
+		defer close(state.done)
+		defer cancel()
+		for _, s := range state.Stages {
+			<-s.Done()
+		}
+		state.err = state.pipefail(p.cmds)
+	}()
+	return state
+}
+
+// 进程描述
+func (p *Pipeline) String() string {
+	names := make([]string, len(p.cmds))
+	for i, c := range p.cmds {
+		names[i] = c.String()
+	}
+	return strings.Join(names, " | ")
+}
+
+type PipelineState struct {
+	Stages []*StartState //已启动的命令，启动失败时只包含到失败的那个
+
+	err    error
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+func (s *PipelineState) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *PipelineState) Cancel() {
+	s.cancel()
+}
+
+// 等待所有命令结束，按 pipefail 返回最后一个失败的命令的错误
+func (s *PipelineState) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// 每个命令的退出结果，未能启动的为 nil
+func (s *PipelineState) Results() []*ExitResult {
+	results := make([]*ExitResult, len(s.Stages))
+	for i, stage := range s.Stages {
+		results[i] = stage.Result()
+	}
+	return results
+}
+
+func (s *PipelineState) pipefail(cmds []*Cmd) error {
+	for i := len(s.Stages) - 1; i >= 0; i-- {
+		if err := s.Stages[i].Err; err != nil {
+			return fmt.Errorf("pipeline stage %d (%s): %w", i, cmds[i], err)
+		}
+	}
+	return nil
+}
+
+// 未能创建的命令
+func failedState(err error) *StartState {
+	done := make(chan struct{})
+	close(done)
+	return &StartState{Err: err, done: done, cancel: func() {}, stopStep: -1}
+}
@@ -0,0 +1,76 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineOutput(t *testing.T) {
+	out := NewBuffer(0, 0)
+	if err := Pipe(Shell(`printf 'b\na\n'`), New("sort").Tee(out)).Run().Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a\nb\n" {
+		t.Fatalf("output: %q", out.String())
+	}
+}
+
+func TestPipelinePipefail(t *testing.T) {
+	for _, c := range []struct {
+		cmds  []*Cmd
+		stage string //出错的命令，空为成功
+		codes []int
+	}{
+		{[]*Cmd{Shell("exit 0"), Shell("exit 0")}, "", []int{0, 0}},
+		{[]*Cmd{Shell("exit 3"), Shell("cat")}, "stage 0", []int{3, 0}},
+		{[]*Cmd{Shell("exit 1"), Shell("cat >/dev/null; exit 2")}, "stage 1", []int{1, 2}},
+	} {
+		p := Pipe(c.cmds...)
+		state := p.Run()
+		err := state.Wait()
+
+		if c.stage == "" && err != nil || c.stage != "" && (err == nil || !strings.Contains(err.Error(), c.stage)) {
+			t.Errorf("%s: got %v, want error in %q", p, err, c.stage)
+		}
+		for i, r := range state.Results() {
+			if r == nil || r.Code != c.codes[i] {
+				t.Errorf("%s: stage %d result %+v, want code %d", p, i, r, c.codes[i])
+			}
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	state := Pipe(Shell("sleep 10"), Shell("sleep 10")).RunWithContext(ctx)
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+
+	select {
+	case <-state.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("pipeline not stopped")
+	}
+	for i, r := range state.Results() {
+		if r == nil || !r.Killed {
+			t.Errorf("stage %d: %+v", i, r)
+		}
+	}
+}
+
+func TestPipelineStartFailure(t *testing.T) {
+	state := Pipe(Shell("sleep 10"), New("/nonexistent/command")).Run()
+
+	select {
+	case <-state.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("first stage not stopped after second failed to start")
+	}
+	if len(state.Stages) != 2 || state.Stages[1].PID != 0 || state.Wait() == nil {
+		t.Fatalf("stages: %d, err: %v", len(state.Stages), state.Wait())
+	}
+}
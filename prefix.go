@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 多个进程共用的输出，每次写入一整行，各进程的行不会交错
+type SharedWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	colors int //已分配的颜色数
+}
+
+func NewSharedWriter(w io.Writer) *SharedWriter {
+	return &SharedWriter{w: w}
+}
+
+// 写入加上前缀的一行
+func (s *SharedWriter) writeLine(prefix, line string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = io.WriteString(s.w, prefix+line+"\n")
+	return
+}
+
+// 按顺序为每个进程分配颜色
+func (s *SharedWriter) nextColor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.colors++
+	return ansiColors[(s.colors-1)%len(ansiColors)]
+}
+
+var ansiColors = []string{"36", "33", "32", "35", "34", "31", "96", "93", "92", "95", "94", "91"}
+
+type PrefixOptions struct {
+	Template   string //前缀模板，可用 {name} {stream} {time} {pid}，默认 "{name} | "
+	TimeFormat string //{time} 的格式，默认 15:04:05.000
+	Name       string //{name}，默认 Cmd 的名称或执行文件名
+	Width      int    //{name} 左对齐的宽度
+	Color      bool   //前缀使用 ANSI 颜色，每个进程按顺序分配
+}
+
+// 输出的每一行加上前缀后写入共享输出，多个进程共用同一个 SharedWriter 时行不会交错，
+// 超长的行按 LineLimit 截断或拆分
+func (c *Cmd) Prefixed(out *SharedWriter, options PrefixOptions) *Cmd {
+	return c.prefixed(out, c.linePrefix(out, options))
+}
+
+// 每次运行创建按行加前缀的输出，结束时输出最后不完整的行
+func (c *Cmd) prefixed(out *SharedWriter, prefix func(stream Stream) func() string) *Cmd {
+	return c.With(runOption(func(cmd *exec.Cmd, r *run) error {
+		stdout := newPrefixWriter(out, prefix(Stdout), c.lineMax, c.lineTruncate)
+		stderr := newPrefixWriter(out, prefix(Stderr), c.lineMax, c.lineTruncate)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		r.cleanup.Append(func() { stdout.Flush(); stderr.Flush() })
+		return nil
+	}))
+}
+
+// 各输出流的前缀，启用颜色时为 Cmd 分配一个颜色
+func (c *Cmd) linePrefix(out *SharedWriter, options PrefixOptions) func(stream Stream) func() string {
+	if options.Template == "" {
+		options.Template = "{name} | "
+	}
+	if options.TimeFormat == "" {
+		options.TimeFormat = "15:04:05.000"
+	}
+	if options.Name == "" {
+		if options.Name = c.name; options.Name == "" {
+			options.Name = filepath.Base(c.executable)
+		}
+	}
+	options.Name = fmt.Sprintf("%-*s", options.Width, options.Name)
+
+	var color string
+	if options.Color {
+		color = out.nextColor()
+	}
+
+	var pid atomic.Int64
+	c.OnStatus(func(state *StartState, t Transition) {
+		if t.To == StatusStarted {
+			pid.Store(int64(state.PID))
+		}
+	})
+
+	return func(stream Stream) func() string {
+		return func() string {
+			p := strings.NewReplacer(
+				"{name}", options.Name,
+				"{stream}", string(stream),
+				"{time}", time.Now().Format(options.TimeFormat),
+				"{pid}", strconv.FormatInt(pid.Load(), 10),
+			).Replace(options.Template)
+			if color != "" {
+				p = "\x1b[" + color + "m" + p + "\x1b[0m"
+			}
+			return p
+		}
+	}
+}
+
+// 按行加前缀写入共享输出
+func newPrefixWriter(out *SharedWriter, prefix func() string, max int, truncate bool) *lineWriter {
+	return newLineWriter(max, truncate, func(line string, _ bool) { out.writeLine(prefix(), line) })
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 就绪探针，设置的条件全部满足才算就绪
+type ReadyOptions struct {
+	TCP  string                                             `json:"tcp,omitempty" yaml:"tcp,omitempty"`   //端口可连接, host:port
+	HTTP string                                             `json:"http,omitempty" yaml:"http,omitempty"` //GET 返回 2xx
+	File string                                             `json:"file,omitempty" yaml:"file,omitempty"` //文件出现
+	Line string                                             `json:"line,omitempty" yaml:"line,omitempty"` //stdout/stderr 出现匹配的行(正则)
+	Func func(ctx context.Context, state *StartState) error `json:"-" yaml:"-"`
+
+	Timeout  time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`   //默认1m
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"` //默认1s
+}
+
+// 设置就绪探针，就绪后才执行 postStart 并关闭 StartState.Ready()，超时未就绪则结束进程
+func (c *Cmd) Ready(options ReadyOptions) *Cmd {
+	c.ready = options
+	return c
+}
+
+// 就绪后关闭，未就绪进程就退出时不会关闭，需同时等待 Done()
+func (s *StartState) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// 就绪的时间，未就绪时为零值
+func (s *StartState) ReadyAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readyAt
+}
+
+func (o ReadyOptions) empty() bool {
+	return o.TCP == "" && o.HTTP == "" && o.File == "" && o.Line == "" && o.Func == nil
+}
+
+// 启动前准备，返回等待就绪的方法；max、truncate 为按行读取的设置
+func (o ReadyOptions) prepare(cmd *exec.Cmd, max int, truncate bool) (wait func(ctx context.Context, state *StartState) error, err error) {
+	if o.empty() {
+		return func(context.Context, *StartState) error { return nil }, nil
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = time.Minute
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+
+	var matched chan struct{}
+	if o.Line != "" {
+		re, err := regexp.Compile(o.Line)
+		if err != nil {
+			return nil, fmt.Errorf("ready line: %w", err)
+		}
+		matched = make(chan struct{})
+		match := matchLine(re, matched)
+		cmd.Stdout = teeWriter(cmd.Stdout, newLineWriter(max, truncate, match))
+		cmd.Stderr = teeWriter(cmd.Stderr, newLineWriter(max, truncate, match))
+	}
+
+	check := func(ctx context.Context, state *StartState) error {
+		if o.TCP != "" {
+			if err := checkTCP(ctx, o.TCP); err != nil {
+				return err
+			}
+		}
+		if o.HTTP != "" {
+			if err := checkHTTP(ctx, o.HTTP); err != nil {
+				return err
+			}
+		}
+		if o.File != "" {
+			if _, err := os.Stat(o.File); err != nil {
+				return err
+			}
+		}
+		if matched != nil {
+			select {
+			case <-matched:
+			default:
+				return fmt.Errorf("no line matched %q", o.Line)
+			}
+		}
+		if o.Func != nil {
+			return o.Func(ctx, state)
+		}
+		return nil
+	}
+
+	wait = func(ctx context.Context, state *StartState) error {
+		ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+
+		for {
+			err := check(ctx, state)
+			if err == nil {
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("not ready after %s: %w", o.Timeout, err)
+			case <-time.After(o.Interval):
+			}
+		}
+	}
+	return
+}
+
+func matchLine(re *regexp.Regexp, matched chan struct{}) func(string, bool) {
+	var once sync.Once
+	return func(line string, _ bool) {
+		select {
+		case <-matched:
+		default:
+			if re.MatchString(line) {
+				once.Do(func() { close(matched) })
+			}
+		}
+	}
+}
+
+func checkTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// 合并输出，原输出为空时只写入 w
+func teeWriter(std io.Writer, w io.Writer) io.Writer {
+	if std == nil {
+		return w
+	}
+	return io.MultiWriter(std, w)
+}
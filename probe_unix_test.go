@@ -0,0 +1,47 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	for _, c := range []struct {
+		command string
+		ready   ReadyOptions
+		err     string //为空时应就绪
+	}{
+		{`sleep 0.2; echo listening; sleep 10`, ReadyOptions{Line: "^listening$", Interval: time.Millisecond * 20}, ""},
+		{`echo starting; sleep 10`, ReadyOptions{Line: "^listening$", Timeout: time.Millisecond * 200, Interval: time.Millisecond * 20}, "not ready after"},
+	} {
+		postStart := make(chan time.Time, 1)
+		begin := time.Now()
+		s := Shell(c.command).Ready(c.ready).PostStart(func(*Cmd) { postStart <- time.Now() }).Run()
+
+		if c.err != "" {
+			if err := s.Wait(); err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: got %v, want %q", c.command, err, c.err)
+			}
+			if isClosed(s.Ready()) || len(postStart) > 0 {
+				t.Errorf("%q: ready without match", c.command)
+			}
+			continue
+		}
+
+		select {
+		case <-s.Ready():
+		case <-s.Done():
+			t.Fatalf("%q: exited before ready: %v", c.command, s.Err)
+		}
+		if at := s.ReadyAt(); at.Sub(begin) < time.Millisecond*200 {
+			t.Errorf("%q: ready after %s, before the line", c.command, at.Sub(begin))
+		}
+		if at := <-postStart; at.Sub(begin) < time.Millisecond*200 {
+			t.Errorf("%q: postStart after %s, before the line", c.command, at.Sub(begin))
+		}
+		s.Stop()
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 每秒的时钟周期数，linux 上基本固定为100
+const clockTicks = 100
+
+// 读取 /proc/<pid>/stat
+func readProc(pid int) (p procInfo, err error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return
+	}
+
+	//进程名可能包含空格和括号，从最后一个 ) 之后开始解析
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return p, fmt.Errorf("parse /proc/%d/stat", pid)
+	}
+	fields := bytes.Fields(data[i+1:]) //fields[0] 为第3列 state
+	if len(fields) < 22 {
+		return p, fmt.Errorf("parse /proc/%d/stat", pid)
+	}
+
+	field := func(n int) int64 { //n 为 man proc 中的列号
+		v, _ := strconv.ParseInt(string(fields[n-3]), 10, 64)
+		return v
+	}
+
+	p.PID = pid
+	p.State = fields[0][0]
+	p.PPID = int(field(4))
+	p.PGID = int(field(5))
+	p.StartTime = uint64(field(22))
+	p.CPU = time.Duration(field(14)+field(15)) * time.Second / clockTicks
+	p.Threads = int(field(20))
+	p.RSS = field(24) * int64(os.Getpagesize())
+	return
+}
+
+// 进程及其所有子孙进程，第一个为 pid 本身
+func procTree(pid int) ([]procInfo, error) {
+	root, err := readProc(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[int][]procInfo{}
+	for _, p := range allProcs() {
+		children[p.PPID] = append(children[p.PPID], p)
+	}
+
+	tree := []procInfo{root}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i].PID]...)
+	}
+
+	for i := range tree {
+		readProcExtra(&tree[i])
+	}
+	return tree, nil
+}
+
+// 所有进程，读取过程中退出的忽略
+func allProcs() (procs []procInfo) {
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		if p, err := readProc(pid); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	return
+}
+
+// 打开的文件数和IO字节数，没有权限时忽略
+func readProcExtra(p *procInfo) {
+	dir := "/proc/" + strconv.Itoa(p.PID)
+	if fds, err := os.ReadDir(dir + "/fd"); err == nil {
+		p.FDs = len(fds)
+	}
+
+	data, err := os.ReadFile(dir + "/io")
+	if err != nil {
+		return
+	}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		k, v, ok := bytes.Cut(line, []byte(": "))
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(string(v), 10, 64)
+		switch string(k) {
+		case "read_bytes":
+			p.ReadBytes = n
+		case "write_bytes":
+			p.WriteBytes = n
+		}
+	}
+}
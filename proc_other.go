@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cmd
+
+import "errors"
+
+func readProc(int) (procInfo, error) { return procInfo{}, errors.New("/proc is not supported") }
+
+func procTree(int) ([]procInfo, error) { return nil, errors.New("/proc is not supported") }
@@ -3,8 +3,10 @@
 package cmd
 
 import (
+	"os"
 	"os/exec"
 	"os/user"
+	"runtime"
 	"strconv"
 	"syscall"
 )
@@ -31,6 +33,26 @@ func setUser(attr *attr, uid uint32, gid uint32) {
 	attr.Credential = &syscall.Credential{Uid: uid, Gid: gid, NoSetGroups: true}
 }
 
-func sysInterrupt(pid int) (err error) { return syscall.Kill(-pid, syscall.SIGINT) }
-func sysTerminate(pid int) (err error) { return syscall.Kill(-pid, syscall.SIGTERM) }
-func sysKill(pid int) (err error)      { return syscall.Kill(-pid, syscall.SIGKILL) }
+// 控制接口可用的信号名称
+var signalNames = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT, "KILL": syscall.SIGKILL, "TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1, "USR2": syscall.SIGUSR2, "CONT": syscall.SIGCONT, "STOP": syscall.SIGSTOP, "WINCH": syscall.SIGWINCH,
+}
+
+// 重新打开日志的信号
+var reopenSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
+
+func sysSignal(pid int, sig syscall.Signal) (err error) { return syscall.Kill(-pid, sig) }
+
+func signalSupported(syscall.Signal) error { return nil }
+
+// linux 下 ru_maxrss 单位为KB，darwin 为字节
+func maxRSS(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok && ru != nil {
+		if runtime.GOOS == "darwin" {
+			return int64(ru.Maxrss)
+		}
+		return int64(ru.Maxrss) << 10
+	}
+	return 0
+}
@@ -3,6 +3,8 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"syscall"
@@ -19,6 +21,21 @@ func killPid(pid int) error {
 	return exec.Command("taskkill", "/f", "/t", "/pid", strconv.Itoa(pid)).Run()
 }
 
-func sysInterrupt(pid int) error { return killPid(pid) }
-func sysTerminate(pid int) error { return killPid(pid) }
-func sysKill(pid int) error      { return killPid(pid) }
+// windows 无法向进程发送信号，统一结束进程树
+func sysSignal(pid int, _ syscall.Signal) error { return killPid(pid) }
+
+// 只有 KILL 和 TERM 能按结束进程树处理，其他信号不能静默地结束进程
+func signalSupported(sig syscall.Signal) error {
+	if sig == syscall.SIGKILL || sig == syscall.SIGTERM {
+		return nil
+	}
+	return fmt.Errorf("signal %s is unsupported on windows", sig)
+}
+
+var reopenSignals []os.Signal
+
+var signalNames = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT, "KILL": syscall.SIGKILL, "TERM": syscall.SIGTERM,
+}
+
+func maxRSS(*os.ProcessState) int64 { return 0 }
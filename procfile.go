@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Procfile 中的一项，name: command
+type ProcfileEntry struct {
+	Name    string
+	Command string
+}
+
+type ProcfileOptions struct {
+	Dir      string    //工作目录，默认 Procfile 所在目录
+	Env      []string  //额外的环境变量
+	BasePort int       //第一个进程的 PORT，默认5000
+	PortStep int       //每个进程 PORT 的增量，默认100
+	Output   io.Writer //输出，默认标准输出
+	NoColor  bool      //不使用颜色，设置了 NO_COLOR 环境变量时也不使用
+}
+
+// 解析 Procfile，忽略空行和 # 开头的注释
+func ParseProcfile(r io.Reader) (entries []ProcfileEntry, err error) {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(line, ":")
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+		if !ok || name == "" || command == "" {
+			return nil, fmt.Errorf("procfile line %d: invalid entry %q", n, line)
+		}
+		entries = append(entries, ProcfileEntry{Name: name, Command: command})
+	}
+	return entries, s.Err()
+}
+
+// 运行 Procfile 中的所有进程，任一进程退出、ctx 结束或收到 Ctrl-C 时停止所有进程
+func RunProcfile(ctx context.Context, path string, options ProcfileOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	entries, err := ParseProcfile(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if options.Dir == "" {
+		options.Dir = filepath.Dir(path)
+	}
+
+	g, err := ProcfileGroup(entries, options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = g.RunWithContext(ctx); err != nil {
+		return err
+	}
+	return g.Wait()
+}
+
+// 每项创建一个 Cmd，分配 PORT 环境变量，输出加上对齐的进程名前缀
+func ProcfileGroup(entries []ProcfileEntry, options ProcfileOptions) (*Group, error) {
+	if options.BasePort <= 0 {
+		options.BasePort = 5000
+	}
+	if options.PortStep <= 0 {
+		options.PortStep = 100
+	}
+	if options.Output == nil {
+		options.Output = os.Stdout
+	}
+	prefix := PrefixOptions{Color: !options.NoColor && os.Getenv("NO_COLOR") == ""}
+
+	width := 0
+	for _, e := range entries {
+		width = max(width, len(e.Name))
+	}
+	prefix.Width = width
+
+	out := NewSharedWriter(options.Output)
+	g := NewGroup().StopOnExit()
+	for i, e := range entries {
+		env := append(append(os.Environ(), options.Env...), "PORT="+strconv.Itoa(options.BasePort+i*options.PortStep))
+		lookup := envLookup(env)
+
+		args := Fields(e.Command)
+		for j, arg := range args {
+			args[j] = os.Expand(arg, lookup)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("procfile %s: empty command", e.Name)
+		}
+
+		c := New(args[0], args[1:]...).With(Envs(env))
+		c.name = e.Name
+		linePrefix := c.linePrefix(out, prefix)
+		c.prefixed(out, linePrefix)
+
+		//启动和退出信息也写到进程的输出中
+		c.OnStatus(func(state *StartState, t Transition) {
+			switch t.To {
+			case StatusStarted:
+				out.writeLine(linePrefix(Stdout)(), fmt.Sprintf("started with pid %d", state.PID))
+			case StatusStopped:
+				msg := "exited"
+				if state.Err != nil {
+					msg += ": " + state.Err.Error()
+				}
+				out.writeLine(linePrefix(Stdout)(), msg)
+			}
+		})
+		if options.Dir != "" {
+			c.With(WorkDir(options.Dir))
+		}
+		g.Add(e.Name, c, RestartOptions{})
+	}
+	return g, nil
+}
+
+// 后面的同名变量覆盖前面的
+func envLookup(env []string) func(string) string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			m[k] = v
+		}
+	}
+	return func(k string) string { return m[k] }
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProcfile(t *testing.T) {
+	for _, c := range []struct {
+		data    string
+		entries []ProcfileEntry
+		err     string
+	}{
+		{"# comment\n\nweb: ./web -port $PORT\n  worker :  ./worker  \n", []ProcfileEntry{{"web", "./web -port $PORT"}, {"worker", "./worker"}}, ""},
+		{"", nil, ""},
+		{"web: ./web\nworker\n", nil, `line 2: invalid entry "worker"`},
+		{": ./web\n", nil, "line 1"},
+		{"# comment\nweb:\n", nil, "line 2"},
+	} {
+		entries, err := ParseProcfile(strings.NewReader(c.data))
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: got %v, want %q", c.data, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(entries, c.entries) {
+			t.Errorf("%q: got %v %v", c.data, entries, err)
+		}
+	}
+}
+
+func TestProcfileGroupPort(t *testing.T) {
+	//printenv 读取环境变量，echo 的参数由 $PORT 展开
+	entries := []ProcfileEntry{
+		{"a", "sh -c 'printenv PORT; echo $PORT; sleep 0.5'"},
+		{"b", "sh -c 'printenv PORT; echo $PORT; sleep 0.5'"},
+	}
+	out := NewBuffer(0, 0)
+	g, err := ProcfileGroup(entries, ProcfileOptions{BasePort: 7000, PortStep: 10, Output: out, NoColor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	g.Wait()
+
+	for _, want := range []string{"a | 7000\n", "b | 7010\n"} {
+		if n := strings.Count(out.String(), want); n != 2 {
+			t.Errorf("%d of %q in:\n%s", n, want, out)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Cmd.Logger 创建的日志文件
+var logs = struct {
+	sync.Mutex
+	writers map[LogWriter]struct{}
+}{writers: map[LogWriter]struct{}{}}
+
+func registerLog(w LogWriter) {
+	logs.Lock()
+	defer logs.Unlock()
+	logs.writers[w] = struct{}{}
+}
+
+func unregisterLog(w LogWriter) {
+	logs.Lock()
+	defer logs.Unlock()
+	delete(logs.writers, w)
+}
+
+// 重新打开所有由 Cmd.Logger 创建的日志文件
+func ReopenLogs() error {
+	logs.Lock()
+	writers := make([]LogWriter, 0, len(logs.writers))
+	for w := range logs.writers {
+		writers = append(writers, w)
+	}
+	logs.Unlock()
+
+	var errs []error
+	for _, w := range writers {
+		errs = append(errs, w.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// 收到信号时重新打开所有日志文件，默认 SIGHUP 和 SIGUSR1(windows 不支持)，返回取消监听的方法
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = reopenSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				ReopenLogs()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
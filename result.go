@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// 进程退出结果
+type ExitResult struct {
+	Code      int            `json:"code"`                 //退出码，被信号结束时为-1
+	Signal    syscall.Signal `json:"signal,omitempty"`     //结束进程的信号
+	CoreDump  bool           `json:"core_dump,omitempty"`  //是否产生了 core dump
+	Killed    bool           `json:"killed,omitempty"`     //是否由我们停止(Terminate、探测失败等)
+	StopStep  int            `json:"stop_step"`            //停止策略执行到的步骤，未停止为-1
+	OOMKilled bool           `json:"oom_killed,omitempty"` //cgroup 中有进程因内存超限被结束，只在使用 Cgroup 时有效
+
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	Duration   time.Duration `json:"duration"`    //运行时长
+	UserTime   time.Duration `json:"user_time"`   //用户态CPU时间
+	SystemTime time.Duration `json:"system_time"` //内核态CPU时间
+	MaxRSS     int64         `json:"max_rss"`     //最大常驻内存(字节)，windows 不支持
+}
+
+func newExitResult(ps *os.ProcessState, start, end time.Time, stopStep int) *ExitResult {
+	r := &ExitResult{
+		Code:      -1,
+		StopStep:  stopStep,
+		Killed:    stopStep >= 0,
+		StartTime: start,
+		EndTime:   end,
+		Duration:  end.Sub(start),
+	}
+
+	if ps != nil {
+		r.Code = ps.ExitCode()
+		r.UserTime = ps.UserTime()
+		r.SystemTime = ps.SystemTime()
+		r.MaxRSS = maxRSS(ps)
+		if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			r.Signal = ws.Signal()
+			r.CoreDump = ws.CoreDump()
+		}
+	}
+	return r
+}
+
+// 退出结果，进程未能启动时为 nil，Done() 之后可用
+func (s *StartState) Result() *ExitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result
+}
+
+func (s *StartState) setStopStep(step int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopStep = step
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// 不限制
+const RlimInfinity = ^uint64(0)
+
+// 资源限制，Resource 为 nofile、nproc、core、as、cpu 等(不区分大小写，可带 RLIMIT_ 前缀)
+type Rlimit struct {
+	Resource string `json:"resource"`
+	Soft     uint64 `json:"soft"`
+	Hard     uint64 `json:"hard"`
+}
+
+// 资源限制，只对子进程生效(目前只支持 linux)。子进程先以当前程序启动，在 exec 目标程序之前设置，
+// 目标程序从第一条指令起就受限制；设置了 User 时也在设置后再切换用户。
+// 无法提高硬限制(需要 CAP_SYS_RESOURCE)时启动失败并返回错误。
+//
+// 设置是在子进程中执行本包的 init 时完成的：Go 按包路径排序初始化，排在 github.com/cnk3x/cmd
+// 之前且不依赖本包的包，其 init 会先在子进程中执行，有副作用(如创建文件、连接网络)时需注意
+func Limit(resource string, soft, hard uint64) Option {
+	return runOption(rlimitOption{{Resource: resource, Soft: soft, Hard: hard}}.prepare)
+}
+
+// 按名称设置多个资源限制，软硬限制相同，用于配置文件，如 {nofile: 65536}
+func Limits(limits map[string]uint64) Option {
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	o := make(rlimitOption, len(names))
+	for i, name := range names {
+		o[i] = Rlimit{Resource: name, Soft: limits[name], Hard: limits[name]}
+	}
+	return runOption(o.prepare)
+}
+
+// 打开文件数
+func LimitNOFILE(n uint64) Option { return Limit("nofile", n, n) }
+
+// 用户进程数
+func LimitNPROC(n uint64) Option { return Limit("nproc", n, n) }
+
+// core 文件大小，0 不生成
+func LimitCORE(n uint64) Option { return Limit("core", n, n) }
+
+// 虚拟内存字节数
+func LimitAS(n uint64) Option { return Limit("as", n, n) }
+
+// CPU 秒数
+func LimitCPU(n uint64) Option { return Limit("cpu", n, n) }
+
+type rlimitOption []Rlimit
+
+// 启动前检查参数
+func (o rlimitOption) prepare(_ *exec.Cmd, r *run) error {
+	for _, l := range o {
+		if _, err := rlimitResource(l.Resource); err != nil {
+			return err
+		}
+		if l.Soft > l.Hard {
+			return fmt.Errorf("rlimit %s: soft limit %d exceeds hard limit %d", l.Resource, l.Soft, l.Hard)
+		}
+	}
+	r.starting = append(r.starting, func(cmd *exec.Cmd) error { return rlimitExec(cmd, r, o) })
+	return nil
+}
+
+func rlimitResource(name string) (int, error) {
+	key := strings.TrimPrefix(strings.ToLower(name), "rlimit_")
+	if r, ok := rlimitResources[key]; ok {
+		return r, nil
+	}
+	return 0, fmt.Errorf("rlimit %s: unknown or unsupported resource", name)
+}
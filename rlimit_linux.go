@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// syscall 中没有的资源，编号随架构不同的在 rlimit_linux_*.go 中定义
+var rlimitResources = map[string]int{
+	"cpu":     syscall.RLIMIT_CPU,
+	"fsize":   syscall.RLIMIT_FSIZE,
+	"data":    syscall.RLIMIT_DATA,
+	"stack":   syscall.RLIMIT_STACK,
+	"core":    syscall.RLIMIT_CORE,
+	"rss":     rlimitRSS,
+	"nproc":   rlimitNPROC,
+	"nofile":  syscall.RLIMIT_NOFILE,
+	"memlock": rlimitMEMLOCK,
+	"as":      syscall.RLIMIT_AS,
+	//以下编号在所有架构上相同
+	"locks":      10,
+	"sigpending": 11,
+	"msgqueue":   12,
+	"nice":       13,
+	"rtprio":     14,
+	"rttime":     15,
+}
+
+const rlimitExecEnv = "CMD_RLIMIT_EXEC" //子进程设置资源限制后要执行的程序
+
+// 子进程中转的参数，通过环境变量传递
+type rlimitTrampoline struct {
+	Path       string              `json:"path"`                 //目标程序
+	Fd         int                 `json:"fd"`                   //错误管道，exec 成功时自动关闭
+	Limits     []Rlimit            `json:"limits"`               //资源限制
+	Credential *syscall.Credential `json:"credential,omitempty"` //设置资源限制后再切换用户，否则无法提高硬限制
+}
+
+// 让子进程先执行当前程序，在本包初始化时设置资源限制后 exec 目标程序；
+// 出错时把错误写入管道并退出，父进程读到错误后启动失败
+func rlimitExec(cmd *exec.Cmd, r *run, limits []Rlimit) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	r.cleanup.Append(WrapClose(pr, pw))
+
+	t := rlimitTrampoline{Path: cmd.Path, Fd: 3 + len(cmd.ExtraFiles), Limits: limits}
+	if attr := cmd.SysProcAttr; attr != nil && attr.Credential != nil {
+		t.Credential, attr.Credential = attr.Credential, nil
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	cmd.Env = append(cmd.Environ(), rlimitExecEnv+"="+string(data))
+	cmd.Path = "/proc/self/exe"
+	cmd.ExtraFiles = append(cmd.ExtraFiles[:len(cmd.ExtraFiles):len(cmd.ExtraFiles)], pw)
+
+	// 等待 exec 完成(管道被关闭)或出错
+	r.started = append(r.started, func(int) error {
+		pw.Close()
+		msg, _ := io.ReadAll(pr)
+		if len(msg) > 0 {
+			return errors.New(string(msg))
+		}
+		return nil
+	})
+	return nil
+}
+
+// 中转：设置资源限制和用户后 exec 目标程序，不会返回。
+// 在本包初始化时执行，先于 main，但排在本包之前且不依赖本包的包已经初始化过
+func init() {
+	data, ok := os.LookupEnv(rlimitExecEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(rlimitExecEnv)
+
+	var t rlimitTrampoline
+	err := json.Unmarshal([]byte(data), &t)
+	if err == nil {
+		err = t.exec()
+	}
+
+	if t.Fd > 2 {
+		io.WriteString(os.NewFile(uintptr(t.Fd), "rlimit"), err.Error())
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(127)
+}
+
+func (t *rlimitTrampoline) exec() error {
+	syscall.CloseOnExec(t.Fd)
+	for _, l := range t.Limits {
+		if err := setRlimit(l); err != nil {
+			return err
+		}
+	}
+
+	if c := t.Credential; c != nil {
+		if !c.NoSetGroups {
+			groups := make([]int, len(c.Groups))
+			for i, g := range c.Groups {
+				groups[i] = int(g)
+			}
+			if err := syscall.Setgroups(groups); err != nil {
+				return fmt.Errorf("setgroups: %w", err)
+			}
+		}
+		if err := syscall.Setgid(int(c.Gid)); err != nil {
+			return fmt.Errorf("setgid %d: %w", c.Gid, err)
+		}
+		if err := syscall.Setuid(int(c.Uid)); err != nil {
+			return fmt.Errorf("setuid %d: %w", c.Uid, err)
+		}
+	}
+
+	err := syscall.Exec(t.Path, os.Args, os.Environ())
+	return &os.PathError{Op: "fork/exec", Path: t.Path, Err: err}
+}
+
+// 设置当前进程的资源限制，nofile 设置后 exec 时不再恢复 Go 运行时启动前的值
+func setRlimit(l Rlimit) error {
+	resource, err := rlimitResource(l.Resource)
+	if err != nil {
+		return err
+	}
+
+	var old syscall.Rlimit
+	if err = syscall.Getrlimit(resource, &old); err != nil {
+		return fmt.Errorf("rlimit %s: %w", l.Resource, err)
+	}
+
+	if err = syscall.Setrlimit(resource, &syscall.Rlimit{Cur: l.Soft, Max: l.Hard}); err != nil {
+		if errors.Is(err, syscall.EPERM) && l.Hard > old.Max {
+			return fmt.Errorf("rlimit %s: cannot raise hard limit from %d to %d (requires CAP_SYS_RESOURCE, nofile is also capped by fs.nr_open): %w", l.Resource, old.Max, l.Hard, err)
+		}
+		return fmt.Errorf("rlimit %s: set %d/%d: %w", l.Resource, l.Soft, l.Hard, err)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package cmd
+
+// asm-generic/resource.h
+const (
+	rlimitRSS     = 5
+	rlimitNPROC   = 6
+	rlimitMEMLOCK = 8
+)
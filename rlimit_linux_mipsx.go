@@ -0,0 +1,10 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package cmd
+
+// mips 的 nofile 和 as 为 5、6，其后依次顺延
+const (
+	rlimitRSS     = 7
+	rlimitNPROC   = 8
+	rlimitMEMLOCK = 9
+)
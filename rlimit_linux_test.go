@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// 子进程 /proc/self/limits 中某一项的软硬限制
+func childLimit(t *testing.T, name string, options ...Option) (soft, hard string) {
+	t.Helper()
+	out, _, err := New("cat", "/proc/self/limits").With(options...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if rest, ok := strings.CutPrefix(line, name); ok {
+			if f := strings.Fields(rest); len(f) >= 2 {
+				return f[0], f[1]
+			}
+		}
+	}
+	t.Fatalf("%s not found in:\n%s", name, out)
+	return
+}
+
+func TestRlimitBeforeExec(t *testing.T) {
+	//exec 前设置，每次都能读到
+	for i := 0; i < 20; i++ {
+		if soft, hard := childLimit(t, "Max open files", LimitNOFILE(64)); soft != "64" || hard != "64" {
+			t.Fatalf("run %d: nofile %s/%s", i, soft, hard)
+		}
+	}
+
+	//只设置其他资源时，nofile 与直接启动相同，不带出 Go 运行时提高的软限制
+	soft, hard := childLimit(t, "Max open files")
+	if s, h := childLimit(t, "Max open files", LimitCORE(0)); s != soft || h != hard {
+		t.Fatalf("nofile %s/%s, want %s/%s", s, h, soft, hard)
+	}
+	if s, _ := childLimit(t, "Max core file size", LimitCORE(0)); s != "0" {
+		t.Fatalf("core %s", s)
+	}
+
+	//编号随架构不同的资源
+	if s, _ := childLimit(t, "Max processes", LimitNPROC(4096)); s != "4096" {
+		t.Fatalf("nproc %s", s)
+	}
+	if s, _ := childLimit(t, "Max locked memory", Limit("memlock", 65536, 65536)); s != "65536" {
+		t.Fatalf("memlock %s", s)
+	}
+}
+
+func TestRlimitError(t *testing.T) {
+	//超过 fs.nr_open，root 也无法设置
+	err := New("true").With(LimitNOFILE(RlimInfinity - 1)).Run().Wait()
+	if err == nil || !strings.Contains(err.Error(), "rlimit nofile") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// 只有 linux 支持在 exec 前设置资源限制
+var rlimitResources = map[string]int{}
+
+func rlimitExec(*exec.Cmd, *run, []Rlimit) error {
+	return errors.New("rlimit: not supported on this platform")
+}
@@ -1,68 +1,137 @@
 package cmd
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
-func Rotate(options LoggerOptions) io.WriteCloser {
-	if minSize := FileSize(1 << 20); options.MaxSize < minSize {
-		options.MaxSize = minSize
-	}
-	if maxSize := FileSize(100 << 20); options.MaxSize > maxSize {
-		options.MaxSize = maxSize
+// 日志文件，文件被外部工具(如 logrotate)移走后可重新打开
+type LogWriter interface {
+	io.WriteCloser
+	Reopen() error //重新打开日志路径
+}
+
+func Rotate(options LoggerOptions) LogWriter {
+	return newRotateWriter(options)
+}
+
+func newRotateWriter(options LoggerOptions) *rotateWriter {
+	//只按时间轮转时，不设置 MaxSize 表示不按大小轮转
+	if options.MaxSize > 0 || options.Interval == "" {
+		if minSize := FileSize(1 << 20); options.MaxSize < minSize {
+			options.MaxSize = minSize
+		}
+		if maxSize := FileSize(100 << 20); options.MaxSize > maxSize {
+			options.MaxSize = maxSize
+		}
 	}
 	if options.Keep < 0 && options.Keep != -1 {
 		options.Keep = 0
 	}
 	return &rotateWriter{
-		Path:    options.Path,
-		MaxSize: int(options.MaxSize),
-		Keep:    options.Keep,
+		Path:     options.Path,
+		MaxSize:  int(options.MaxSize),
+		Keep:     options.Keep,
+		Interval: options.Interval,
+		Compress: options.Compress,
+
+		MaxAge:       options.MaxAge,
+		MaxTotalSize: int64(options.MaxTotalSize),
+		RotateOnOpen: options.RotateOnOpen,
 	}
 }
 
 type LoggerOptions struct {
-	Std     bool     `json:"std"`
-	Path    string   `json:"path"`
-	MaxSize FileSize `json:"max_size" yaml:"max_size"` //默认1M，最大100M
-	Keep    int      `json:"keep"`                     //0, 不保留, -1, 保留所有
+	Std      bool     `json:"std"`
+	Path     string   `json:"path"`
+	MaxSize  FileSize `json:"max_size" yaml:"max_size"` //默认1M，最大100M
+	Keep     int      `json:"keep"`                     //0, 不保留, -1, 保留所有
+	Interval string   `json:"interval"`                 //按时间轮转: hourly, daily, weekly, monthly 或 cron 表达式(分 时 日 月 周)
+	Compress string   `json:"compress"`                 //轮转后的文件压缩格式: gzip 或 zstd
+
+	MaxAge       int      `json:"max_age" yaml:"max_age"`               //轮转文件保留天数，0不限制
+	MaxTotalSize FileSize `json:"max_total_size" yaml:"max_total_size"` //轮转文件总大小上限，0不限制
+	RotateOnOpen bool     `json:"rotate_on_open" yaml:"rotate_on_open"` //每次启动都先轮转已有的日志，每次运行一个文件
 }
 
 type rotateWriter struct {
-	Path    string
-	MaxSize int //默认1M，最大100M
-	Keep    int //0, 不保留, -1, 保留所有
-
-	current *os.File
-	size    int
-	err     error
-	setup   sync.Once
-	mu      sync.Mutex
+	Path     string
+	MaxSize  int    //默认1M，最大100M，0不按大小轮转
+	Keep     int    //0, 不保留, -1, 保留所有
+	Interval string //按时间轮转
+	Compress string //轮转后压缩
+
+	MaxAge       int   //轮转文件保留天数，0不限制
+	MaxTotalSize int64 //轮转文件总大小上限，0不限制
+	RotateOnOpen bool  //打开时轮转已有的日志
+
+	written  *atomic.Int64 //累计写入的字节数，可为 nil
+	current  *os.File
+	size     int
+	schedule Schedule
+	next     time.Time //下次按时间轮转的时间
+	checked  time.Time //上次检查文件是否被替换的时间
+	err      error
+	setup    sync.Once
+	mu       sync.Mutex
+	bg       sync.WaitGroup //后台压缩
+
+	cmu         sync.Mutex
+	compressing map[string]bool //后台压缩中的文件，清理时跳过，压缩完成后再清理
 }
 
 func (r *rotateWriter) Write(p []byte) (n int, err error) {
-	r.setup.Do(func() { r.err = r.open() })
+	r.setup.Do(func() {
+		if r.err = r.open(); r.err == nil {
+			r.recover()
+			if r.RotateOnOpen && r.size > 0 {
+				r.err = r.rotate()
+			}
+			r.prune()
+		}
+	})
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if err = r.err; err != nil {
 		return
 	}
+	//路径被移走或替换(inode 变化)时重新打开，每秒最多检查一次
+	if now := time.Now(); now.Sub(r.checked) >= time.Second {
+		r.checked = now
+		if r.replaced() {
+			if err = r.reopen(); err != nil {
+				return
+			}
+		}
+	}
+	if !r.next.IsZero() && !time.Now().Before(r.next) {
+		if err = r.rotate(); err != nil {
+			return
+		}
+	}
 	if n, err = r.current.Write(p); err != nil {
 		return
 	}
 	r.size += n
-	if r.size >= int(r.MaxSize) {
+	if r.written != nil {
+		r.written.Add(int64(n))
+	}
+	if r.MaxSize > 0 && r.size >= r.MaxSize {
 		if err = r.rotate(); err != nil {
 			return
 		}
@@ -70,7 +139,9 @@ func (r *rotateWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// 关闭文件并等待后台压缩完成
 func (r *rotateWriter) Close() (err error) {
+	defer r.bg.Wait()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.current != nil {
@@ -82,16 +153,67 @@ func (r *rotateWriter) Close() (err error) {
 	return
 }
 
+// 重新打开日志路径，用于外部轮转后
+func (r *rotateWriter) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil { //未打开或已关闭
+		return nil
+	}
+	return r.reopen()
+}
+
+func (r *rotateWriter) reopen() (err error) {
+	if err = r.current.Close(); err != nil {
+		return
+	}
+	r.current = nil
+	return r.open()
+}
+
+func (r *rotateWriter) replaced() bool {
+	if r.current == nil {
+		return false
+	}
+	cur, err := r.current.Stat()
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(r.Path)
+	return err != nil || !os.SameFile(cur, fi)
+}
+
 func (r *rotateWriter) open() (err error) {
 	if err = os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
 		return
 	}
 
+	if _, err = compressExt(r.Compress); err != nil {
+		return
+	}
+
+	if r.schedule == nil && r.Interval != "" {
+		if r.schedule, err = ParseSchedule(r.Interval); err != nil {
+			return
+		}
+	}
+
 	if r.current, err = os.OpenFile(r.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666); err != nil {
 		return
 	}
 
+	//追加写入，已有内容计入大小
 	r.size = 0
+	from := time.Now()
+	if fi, e := r.current.Stat(); e == nil && fi.Size() > 0 {
+		r.size = int(fi.Size())
+		from = fi.ModTime()
+	}
+
+	//按已有文件的修改时间计算，跨过了轮转时间点的旧文件会在下次写入时轮转
+	if r.schedule != nil {
+		r.next = r.schedule.Next(from)
+	}
 	return
 }
 
@@ -105,37 +227,230 @@ func (r *rotateWriter) rotate() (err error) {
 			return
 		}
 	} else {
-		var (
-			dir, fname = filepath.Split(r.Path)
-			ext        = filepath.Ext(fname)
-			name       = strings.TrimSuffix(fname, ext)
-			now        = time.Now().Format("20060102-150405")
-			fpath      = filepath.Join(dir, fmt.Sprintf("%s-%s%s", name, now, ext))
-		)
-
+		fpath := r.segmentPath(time.Now())
 		if err = os.Rename(r.Path, fpath); err != nil {
 			return
 		}
 
-		if r.Keep > 0 {
-			matches, e := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s-*%s", name, ext)))
-			if e != nil {
-				err = e
-				return
-			}
-			sort.Strings(matches)
-			for i, n := range matches {
-				if i < r.Keep {
-					continue
-				}
-				_ = os.Remove(n) //删除失败不报错
-			}
+		if r.Compress != "" {
+			r.compress(fpath)
 		}
+		r.prune()
 	}
 
 	return r.open()
 }
 
+// 轮转后的文件名 name-20060102-150405.ext，同一秒内多次轮转时加上 -N 后缀，不覆盖已有的文件(包括压缩后的)
+func (r *rotateWriter) segmentPath(now time.Time) string {
+	var (
+		dir, fname = filepath.Split(r.Path)
+		ext        = filepath.Ext(fname)
+		name       = strings.TrimSuffix(fname, ext)
+		stem       = filepath.Join(dir, name+"-"+now.Format("20060102-150405"))
+	)
+
+	for n := 0; ; n++ {
+		path := stem + ext
+		if n > 0 {
+			path = stem + "-" + strconv.Itoa(n) + ext
+		}
+		if !fileExists(path) && !fileExists(path+".gz") && !fileExists(path+".zst") {
+			return path
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// 按数量、时间和总大小清理轮转文件，删除失败不报错
+func (r *rotateWriter) prune() {
+	var (
+		total    int64
+		deadline time.Time
+	)
+	if r.MaxAge > 0 {
+		deadline = time.Now().AddDate(0, 0, -r.MaxAge)
+	}
+
+	for i, seg := range r.segments() {
+		total += seg.size
+		switch {
+		case r.Keep > 0 && i >= r.Keep,
+			!deadline.IsZero() && seg.time.Before(deadline),
+			r.MaxTotalSize > 0 && total > r.MaxTotalSize && i > 0: //最新的一个始终保留
+			if !r.isCompressing(seg.stem) {
+				seg.remove()
+			}
+		}
+	}
+}
+
+// 已轮转的文件(name-20060102-150405[-N].ext[.gz|.zst])，压缩中的同一个文件只算一个
+type logSegment struct {
+	stem  string    //未压缩时的路径
+	paths []string  //同一个文件压缩前后的所有文件
+	time  time.Time //轮转时间
+	seq   int       //同一秒内轮转的序号
+	size  int64
+}
+
+func (s logSegment) remove() {
+	for _, path := range s.paths {
+		_ = os.Remove(path)
+	}
+}
+
+// 按时间从新到旧列出已轮转的文件
+func (r *rotateWriter) segments() (segments []logSegment) {
+	var (
+		dir, fname = filepath.Split(r.Path)
+		ext        = filepath.Ext(fname)
+		name       = strings.TrimSuffix(fname, ext)
+		re         = regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-(\d{8}-\d{6})(?:-(\d+))?` + regexp.QuoteMeta(ext) + `(\.gz|\.zst)?$`)
+	)
+
+	entries, _ := os.ReadDir(filepath.Clean(dir))
+	index := map[string]int{}
+	for _, entry := range entries {
+		m := re.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		var size int64
+		if fi, err := entry.Info(); err == nil {
+			size = fi.Size()
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		stem := strings.TrimSuffix(path, m[3])
+		if i, ok := index[stem]; ok {
+			segments[i].paths = append(segments[i].paths, path)
+			segments[i].size = max(segments[i].size, size) //压缩中的文件只算一份
+			continue
+		}
+
+		t, _ := time.ParseInLocation("20060102-150405", m[1], time.Local)
+		seq, _ := strconv.Atoi(m[2])
+		index[stem] = len(segments)
+		segments = append(segments, logSegment{stem: stem, paths: []string{path}, time: t, seq: seq, size: size})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		a, b := segments[i], segments[j]
+		if !a.time.Equal(b.time) {
+			return a.time.After(b.time)
+		}
+		return a.seq > b.seq
+	})
+	return
+}
+
+// 后台压缩，完成后才删除原文件，中途崩溃不会丢失原文件；压缩期间清理会跳过该文件，完成后重新清理
+func (r *rotateWriter) compress(path string) {
+	ext, _ := compressExt(r.Compress)
+
+	r.cmu.Lock()
+	if r.compressing == nil {
+		r.compressing = map[string]bool{}
+	}
+	r.compressing[path] = true
+	r.cmu.Unlock()
+
+	bgRun(&r.bg, func() {
+		if err := compressFile(path, ext); err != nil {
+			_ = os.Remove(path + ext + ".tmp")
+		}
+
+		r.cmu.Lock()
+		delete(r.compressing, path)
+		r.cmu.Unlock()
+		r.prune()
+	})
+}
+
+func (r *rotateWriter) isCompressing(path string) bool {
+	r.cmu.Lock()
+	defer r.cmu.Unlock()
+	return r.compressing[path]
+}
+
+// 处理上次遗留的未压缩文件和压缩到一半的临时文件
+func (r *rotateWriter) recover() {
+	if r.Compress == "" {
+		return
+	}
+	ext, _ := compressExt(r.Compress)
+	for _, seg := range r.segments() {
+		_ = os.Remove(seg.stem + ext + ".tmp")
+		if _, err := os.Stat(seg.stem); err == nil {
+			r.compress(seg.stem)
+		}
+	}
+}
+
+// 压缩格式对应的扩展名
+func compressExt(format string) (string, error) {
+	switch format {
+	case "":
+		return "", nil
+	case "gzip", "gz":
+		return ".gz", nil
+	case "zstd", "zst":
+		return ".zst", nil
+	}
+	return "", fmt.Errorf("unsupported log compression: %s", format)
+}
+
+// 压缩为 src+ext
+func compressFile(src, ext string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	dst := src + ext
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+	defer out.Close()
+
+	var w io.WriteCloser
+	if ext == ".zst" {
+		if w, err = zstd.NewWriter(out); err != nil {
+			return
+		}
+	} else {
+		gz := gzip.NewWriter(out)
+		gz.Name = filepath.Base(src)
+		w = gz
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		w.Close()
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	if err = out.Sync(); err != nil {
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return
+	}
+	return os.Remove(src)
+}
+
 var fileSizeUnit = []string{"K", "M", "G", "T", "P", "E"}
 
 type FileSize float64
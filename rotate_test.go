@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateOnOpenUniqueNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	for _, run := range []string{"run0", "run1", "run2"} {
+		w := newRotateWriter(LoggerOptions{Path: path, Keep: -1, RotateOnOpen: true})
+		w.Write([]byte(run))
+		w.Close()
+	}
+
+	var got []string
+	for _, seg := range newRotateWriter(LoggerOptions{Path: path}).segments() {
+		data, _ := os.ReadFile(seg.stem)
+		got = append(got, string(data))
+	}
+	if strings.Join(got, ",") != "run1,run0" {
+		t.Fatalf("segments: %q", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "run2" {
+		t.Fatalf("current: %q", data)
+	}
+}
+
+func TestRotateSizeOnReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	os.WriteFile(path, []byte(strings.Repeat("x", 100)), 0644)
+
+	w := newRotateWriter(LoggerOptions{Path: path, Keep: 1})
+	w.MaxSize = 150
+	w.Write([]byte(strings.Repeat("y", 40)))
+	if w.size != 140 {
+		t.Fatalf("size after append: %d", w.size)
+	}
+
+	w.Write([]byte(strings.Repeat("y", 10)))
+	w.Close()
+
+	segs := w.segments()
+	if len(segs) != 1 || segs[0].size != 150 {
+		t.Fatalf("segments: %+v", segs)
+	}
+	if fi, _ := os.Stat(path); fi.Size() != 0 {
+		t.Fatalf("current size: %d", fi.Size())
+	}
+}
+
+func TestRotatePrune(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		options LoggerOptions
+		want    string
+	}{
+		{"keep", LoggerOptions{Keep: 2}, "d0,d1"},
+		{"keep all", LoggerOptions{Keep: -1}, "d0,d1,d2,d3"},
+		{"max age", LoggerOptions{Keep: -1, MaxAge: 2}, "d0,d1"},
+		{"max total size", LoggerOptions{Keep: -1, MaxTotalSize: 25}, "d0,d1"},
+		{"newest is kept", LoggerOptions{Keep: -1, MaxTotalSize: 5}, "d0"},
+	} {
+		dir := t.TempDir()
+		c.options.Path = filepath.Join(dir, "app.log")
+
+		//d0 最新，每个 10 字节，d3 已压缩
+		for i := 0; i < 4; i++ {
+			stamp := time.Now().AddDate(0, 0, -i).Add(-time.Hour).Format("20060102-150405")
+			path := filepath.Join(dir, "app-"+stamp+".log")
+			if i == 3 {
+				path += ".gz"
+			}
+			os.WriteFile(path, []byte(fmt.Sprintf("d%d%s", i, strings.Repeat(".", 8))), 0644)
+		}
+
+		w := newRotateWriter(c.options)
+		w.prune()
+
+		var got []string
+		for _, seg := range w.segments() {
+			data, _ := os.ReadFile(seg.paths[0])
+			got = append(got, string(data[:2]))
+		}
+		if strings.Join(got, ",") != c.want {
+			t.Errorf("%s: got %q, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRotatePruneSkipsCompressing(t *testing.T) {
+	dir := t.TempDir()
+	w := newRotateWriter(LoggerOptions{Path: filepath.Join(dir, "app.log"), Keep: 1})
+	old := filepath.Join(dir, "app-20240101-000000.log")
+	os.WriteFile(filepath.Join(dir, "app-20240102-000000.log"), nil, 0644)
+	os.WriteFile(old, nil, 0644)
+
+	w.compressing = map[string]bool{old: true}
+	w.prune()
+	if !fileExists(old) {
+		t.Fatal("segment being compressed was removed")
+	}
+
+	w.compressing = nil
+	w.prune()
+	if fileExists(old) {
+		t.Fatal("segment was kept after compression finished")
+	}
+}
+
+func TestRotateReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := newRotateWriter(LoggerOptions{Path: path, Keep: -1})
+	defer w.Close()
+
+	read := func(path string) string { data, _ := os.ReadFile(path); return string(data) }
+
+	//外部轮转后调用 Reopen
+	w.Write([]byte("a"))
+	os.Rename(path, path+".1")
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("b"))
+	if read(path+".1") != "a" || read(path) != "b" {
+		t.Fatalf("after Reopen: %q %q", read(path+".1"), read(path))
+	}
+
+	//未调用 Reopen 时，超过1秒后的写入检测到 inode 变化
+	os.Rename(path, path+".2")
+	w.Write([]byte("c"))
+	if read(path+".2") != "bc" {
+		t.Fatalf("checked within a second: %q", read(path+".2"))
+	}
+	w.mu.Lock()
+	w.checked = w.checked.Add(-time.Second)
+	w.mu.Unlock()
+	w.Write([]byte("d"))
+	if read(path+".2") != "bc" || read(path) != "d" {
+		t.Fatalf("after inode change: %q %q", read(path+".2"), read(path))
+	}
+}
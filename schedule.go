@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 时间计划，返回 t 之后的下一个时间点
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+var scheduleAlias = map[string]string{
+	"hourly":  "0 * * * *",
+	"daily":   "0 0 * * *",
+	"weekly":  "0 0 * * 0",
+	"monthly": "0 0 1 * *",
+}
+
+// 解析时间计划，支持 hourly, daily, weekly, monthly(可带@前缀) 以及5段式 cron 表达式(分 时 日 月 周)
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if alias, ok := scheduleAlias[strings.TrimPrefix(spec, "@")]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields", spec)
+	}
+
+	var (
+		c      cron
+		bounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+		sets   = [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	)
+	for i, field := range fields {
+		bits, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		*sets[i] = bits
+	}
+
+	if c.dow&(1<<7) != 0 { //7 也表示周日
+		c.dow |= 1
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func (c cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// 日和周都有限制时满足其一即可，与 cron 一致
+func (c cron) dayMatch(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// 解析 cron 单个字段: *, */n, a, a-b, a-b/n 以及逗号分隔的列表
+func parseCronField(field string, min, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		var (
+			lo, hi = min, max
+			step   = 1
+		)
+
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:i]
+		}
+
+		if part != "*" {
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else if lo, err = strconv.Atoi(part); err == nil && step == 1 {
+				hi = lo
+			}
+			if err != nil {
+				return 0, fmt.Errorf("invalid field %q", field)
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("field %q out of range %d-%d", field, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, 2, 28, 23, 30, 15, 0, time.Local) //周三
+
+	for spec, want := range map[string]time.Time{
+		"hourly":       time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local),
+		"@daily":       time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local),
+		"weekly":       time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local),
+		"monthly":      time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		"*/20 * * * *": time.Date(2024, 2, 28, 23, 40, 0, 0, time.Local),
+		"0 6-8 * * *":  time.Date(2024, 2, 29, 6, 0, 0, 0, time.Local),
+		"0 0 30 * 5":   time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		"15 2 29 2 *":  time.Date(2024, 2, 29, 2, 15, 0, 0, time.Local),
+	} {
+		s, err := ParseSchedule(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if got := s.Next(base); !got.Equal(want) {
+			t.Errorf("%s: got %s, want %s", spec, got, want)
+		}
+	}
+
+	for _, spec := range []string{"", "yearly", "60 * * * *", "* * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusStarting Status = "starting"
+	StatusStarted  Status = "started"
+	StatusStopping Status = "stopping"
+	StatusStopped  Status = "stopped"
+)
+
+// 状态变化
+type Transition struct {
+	From Status    `json:"from"`
+	To   Status    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// 状态机，只允许 starting -> started -> stopping -> stopped 单向推进(可跳过中间状态)
+type statusMachine struct {
+	mu          sync.Mutex
+	status      Status
+	transitions []Transition
+	subscribers []chan Transition
+	hooks       []func(Transition)
+}
+
+var statusOrder = map[Status]int{StatusStarting: 1, StatusStarted: 2, StatusStopping: 3, StatusStopped: 4}
+
+// 推进到指定状态，不能后退，重复设置忽略
+func (m *statusMachine) set(to Status) bool {
+	m.mu.Lock()
+	if statusOrder[to] <= statusOrder[m.status] {
+		m.mu.Unlock()
+		return false
+	}
+
+	t := Transition{From: m.status, To: to, Time: time.Now()}
+	m.status = to
+	m.transitions = append(m.transitions, t)
+
+	for _, ch := range m.subscribers {
+		ch <- t //缓冲足够容纳所有状态，不会阻塞
+		if to == StatusStopped {
+			close(ch)
+		}
+	}
+	if to == StatusStopped {
+		m.subscribers = nil
+	}
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(t)
+	}
+	return true
+}
+
+func (m *statusMachine) get() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// 订阅状态变化，先回放已发生的变化，进入 stopped 后关闭通道
+func (m *statusMachine) subscribe() <-chan Transition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan Transition, len(statusOrder))
+	for _, t := range m.transitions {
+		ch <- t
+	}
+	if m.status == StatusStopped {
+		close(ch)
+	} else {
+		m.subscribers = append(m.subscribers, ch)
+	}
+	return ch
+}
+
+// 当前状态
+func (s *StartState) Status() Status {
+	return s.status.get()
+}
+
+// 所有状态变化及其时间
+func (s *StartState) Transitions() []Transition {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	return append([]Transition(nil), s.status.transitions...)
+}
+
+// 进入指定状态的时间，未到达时为零值
+func (s *StartState) Since(status Status) time.Time {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	for _, t := range s.status.transitions {
+		if t.To == status {
+			return t.Time
+		}
+	}
+	return time.Time{}
+}
+
+// 订阅状态变化，通道会先收到已发生的变化，进程停止后关闭
+func (s *StartState) Subscribe() <-chan Transition {
+	return s.status.subscribe()
+}
+
+// 状态变化回调，对之后每次运行都生效(包括 Supervisor 的重启)，回调同步执行，不要阻塞
+func (c *Cmd) OnStatus(fn func(state *StartState, t Transition)) *Cmd {
+	c.onStatus = append(c.onStatus, fn)
+	return c
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// 标准输入，非文件的 Reader 通过管道转发，进程退出后关闭管道，不等待阻塞中的 Reader；
+// 多次运行(如 Supervisor 重启)共用一个转发协程，进程退出时已读取但未写入的数据留给下一次运行
+func (c *Cmd) Stdin(r io.Reader) *Cmd {
+	if f, ok := r.(*os.File); ok {
+		return c.With(FOption(func(cmd *exec.Cmd) { cmd.Stdin = f }))
+	}
+	return c.With(runOption((&stdinForwarder{r: r}).attach))
+}
+
+// 字符串作为标准输入，每次运行都从头读取
+func (c *Cmd) StdinString(s string) *Cmd {
+	return c.With(runOption(func(cmd *exec.Cmd, r *run) error { return pipeStdin(cmd, r, strings.NewReader(s)) }))
+}
+
+// 文件作为标准输入，每次运行时打开，结束后关闭
+func (c *Cmd) StdinFile(path string) *Cmd {
+	return c.With(runOption(func(cmd *exec.Cmd, r *run) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		r.cleanup.Append(WrapClose(f))
+		cmd.Stdin = f
+		return nil
+	}))
+}
+
+// 转发当前进程的标准输入，子进程在独立的进程组中，直接共用终端会被 SIGTTIN 暂停，所以通过管道转发
+func (c *Cmd) InheritStdin() *Cmd {
+	return c.Stdin(struct{ io.Reader }{os.Stdin})
+}
+
+// 启动后通过 StartState.Stdin() 向进程写入，适用于交互式进程
+func (c *Cmd) StdinPipe() *Cmd {
+	c.stdinPipe = true
+	return c
+}
+
+// 标准输入管道，未设置 StdinPipe 时为 nil；关闭即发送 EOF，进程退出后自动关闭
+func (s *StartState) Stdin() io.WriteCloser {
+	return s.stdin
+}
+
+// exec 自带的转发会让 Wait 一直等到 Reader 返回，这里自行转发，进程结束时关闭管道
+func pipeStdin(cmd *exec.Cmd, r *run, src io.Reader) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		io.Copy(pw, src)
+		pw.Close()
+	}()
+
+	stdinPipe(cmd, r, pr)
+	r.cleanup.Append(WrapClose(pw))
+	return nil
+}
+
+// 管道读端作为标准输入，启动后关闭本进程的副本，进程退出后写入即返回 EPIPE
+func stdinPipe(cmd *exec.Cmd, r *run, pr *os.File) {
+	cmd.Stdin = pr
+	r.started = append(r.started, func(int) error { return pr.Close() })
+	r.cleanup.Append(WrapClose(pr)) //启动失败时
+}
+
+// 把一个 Reader 转发给每次运行的标准输入，只有一个协程读取 Reader，
+// 不会因为重启而留下多个争抢输入的协程
+type stdinForwarder struct {
+	r    io.Reader
+	once sync.Once
+	mu   sync.Mutex
+	cond *sync.Cond
+	w    *os.File //当前运行的管道，没有运行时为 nil
+	eof  bool     //Reader 已结束，之后的运行直接得到 EOF
+}
+
+func (f *stdinForwarder) attach(cmd *exec.Cmd, r *run) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	stdinPipe(cmd, r, pr)
+	r.cleanup.Append(func() { f.detach(pw) })
+
+	f.once.Do(func() {
+		f.cond = sync.NewCond(&f.mu)
+		go f.forward()
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.eof {
+		pw.Close()
+		return nil
+	}
+	f.w = pw
+	f.cond.Broadcast()
+	return nil
+}
+
+// 运行结束，不再向 w 写入
+func (f *stdinForwarder) detach(w *os.File) {
+	f.mu.Lock()
+	if f.w == w {
+		f.w = nil
+	}
+	f.mu.Unlock()
+	w.Close()
+}
+
+// 等待有运行中的进程
+func (f *stdinForwarder) target() *os.File {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.w == nil {
+		f.cond.Wait()
+	}
+	return f.w
+}
+
+func (f *stdinForwarder) forward() {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := f.r.Read(buf)
+		for p := buf[:n]; len(p) > 0; {
+			w := f.target()
+			m, werr := w.Write(p)
+			if p = p[m:]; werr != nil { //进程已退出或关闭了标准输入，剩余的写给下一次运行
+				f.detach(w)
+			}
+		}
+
+		if err != nil {
+			f.mu.Lock()
+			f.eof = true
+			if f.w != nil {
+				f.w.Close()
+			}
+			f.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 时钟，停止流程中的等待都通过它进行，便于测试时替换
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// 停止步骤，发送信号(Signal)或执行停止命令(Command)，然后最多等待 Timeout
+type StopStep struct {
+	Signal  syscall.Signal `json:"signal,omitempty" yaml:"signal,omitempty"`
+	Command string         `json:"command,omitempty" yaml:"command,omitempty"` //通过 Shell 执行，环境变量 MAINPID 为进程ID
+	Timeout time.Duration  `json:"timeout,omitempty" yaml:"timeout,omitempty"` //最后一步可为0
+}
+
+// 停止策略，按顺序执行各步骤，进程退出即结束
+type StopPolicy []StopStep
+
+// 默认停止策略: SIGINT, 3秒后 SIGTERM, 再2秒后 SIGKILL
+var DefaultStopPolicy = StopPolicy{
+	{Signal: syscall.SIGINT, Timeout: time.Second * 3},
+	{Signal: syscall.SIGTERM, Timeout: time.Second * 2},
+	{Signal: syscall.SIGKILL},
+}
+
+// 设置停止策略，Terminate 和 StartState.Stop 都使用它
+func (c *Cmd) StopPolicy(steps ...StopStep) *Cmd {
+	c.stopPolicy = steps
+	return c
+}
+
+// 设置停止流程使用的时钟
+func (c *Cmd) Clock(clock Clock) *Cmd {
+	c.clock = clock
+	return c
+}
+
+// 按策略停止进程，返回最后执行的步骤序号；done 为 nil 时只执行第一步
+func (p StopPolicy) Terminate(pid int, done <-chan struct{}, clock Clock) (step int) {
+	return p.terminate(pid, done, clock, nil, nil)
+}
+
+// signal 为 nil 时只向进程组发送信号
+func (p StopPolicy) terminate(pid int, done <-chan struct{}, clock Clock, onStep func(step int), signal func(pid int, sig syscall.Signal) error) (step int) {
+	if len(p) == 0 {
+		p = DefaultStopPolicy
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	if signal == nil {
+		signal = sysSignal
+	}
+
+	for i, s := range p {
+		step = i
+		if onStep != nil {
+			onStep(i)
+		}
+		s.run(pid, signal)
+
+		if done == nil {
+			return
+		}
+
+		if s.Timeout <= 0 {
+			continue
+		}
+
+		select {
+		case <-done:
+			return
+		case <-clock.After(s.Timeout):
+		}
+	}
+	return
+}
+
+func (s StopStep) run(pid int, signal func(pid int, sig syscall.Signal) error) {
+	if s.Command != "" {
+		envs := append(os.Environ(), "MAINPID="+strconv.Itoa(pid))
+		stop := Shell(s.Command).With(Envs(envs)).Standard()
+		go func() {
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if s.Timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+			}
+			defer cancel()
+			stop.RunWithContext(ctx).Wait()
+		}()
+		return
+	}
+	if s.Signal != 0 {
+		signal(pid, s.Signal)
+	}
+}
+
+// 向进程组发送信号，同时发给本次运行的 cgroup 等附加的目标
+func (r *run) signal(pid int, sig syscall.Signal) error {
+	err := sysSignal(pid, sig)
+	for _, fn := range r.signalers {
+		fn(pid, sig)
+	}
+	return err
+}
+
+// 停止进程并等待结束，使用 Cmd 设置的停止策略
+func (s *StartState) Stop() error {
+	s.Cancel()
+	return s.Wait()
+}
+
+// 按默认策略停止进程
+func Terminate(pid int, done <-chan struct{}) {
+	DefaultStopPolicy.Terminate(pid, done, nil)
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	after chan time.Duration
+	fire  chan time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return time.Now() }
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.after <- d
+	return f.fire
+}
+
+func TestStopPolicy(t *testing.T) {
+	clock := &fakeClock{after: make(chan time.Duration, 1), fire: make(chan time.Time)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := Shell(`trap '' INT; sleep 10`).
+		StopPolicy(
+			StopStep{Signal: syscall.SIGINT, Timeout: time.Hour},
+			StopStep{Signal: syscall.SIGTERM, Timeout: time.Hour},
+		).
+		Clock(clock).
+		RunWithContext(ctx)
+
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+
+	if d := <-clock.after; d != time.Hour {
+		t.Fatalf("first step timeout: %s", d)
+	}
+
+	select {
+	case <-s.Done():
+		t.Fatal("process should ignore SIGINT")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	clock.fire <- time.Now()
+	<-clock.after
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("process should exit on SIGTERM")
+	}
+
+	if s.Status() != StatusStopped {
+		t.Fatalf("status: %s", s.Status())
+	}
+}
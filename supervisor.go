@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 重启策略
+type RestartPolicy string
+
+const (
+	RestartNever         RestartPolicy = "never"          //不重启
+	RestartOnFailure     RestartPolicy = "on-failure"     //异常退出(启动失败或退出码非0)时重启
+	RestartAlways        RestartPolicy = "always"         //总是重启
+	RestartUnlessStopped RestartPolicy = "unless-stopped" //与 always 相同：主动停止(Stop 或 ctx 结束)本来就不重启，也不会像 Docker 那样在守护进程重启后恢复
+)
+
+type RestartOptions struct {
+	Policy      RestartPolicy `json:"policy"`
+	Delay       time.Duration `json:"delay"`                            //首次重启延迟，默认1s
+	MaxDelay    time.Duration `json:"max_delay" yaml:"max_delay"`       //最大重启延迟，默认1m
+	Jitter      float64       `json:"jitter"`                           //延迟随机抖动比例(0~1)，0不抖动，超出范围时为0.2
+	MaxRestarts int           `json:"max_restarts" yaml:"max_restarts"` //统计窗口内最多重启次数，0不限制
+	Window      time.Duration `json:"window"`                           //统计窗口，默认10m
+}
+
+// 守护状态
+type SuperviseStatus string
+
+const (
+	SuperviseRunning SuperviseStatus = "running" //进程运行中
+	SuperviseBackoff SuperviseStatus = "backoff" //等待重启
+	SuperviseExited  SuperviseStatus = "exited"  //进程退出，按策略不再重启
+	SuperviseStopped SuperviseStatus = "stopped" //被主动停止
+	SuperviseGaveUp  SuperviseStatus = "gave-up" //重启次数超限，放弃
+)
+
+// 按重启策略守护进程，每次重启都会重新执行 RunWithContext，复用其中的 Terminate 和 postStart/preExit 钩子
+func Supervise(c *Cmd, options RestartOptions) *Supervisor {
+	if options.Policy == "" {
+		options.Policy = RestartNever
+	}
+	if options.Delay <= 0 {
+		options.Delay = time.Second
+	}
+	if options.MaxDelay < options.Delay {
+		options.MaxDelay = max(time.Minute, options.Delay)
+	}
+	if options.Jitter < 0 || options.Jitter > 1 {
+		options.Jitter = 0.2
+	}
+	if options.Window <= 0 {
+		options.Window = time.Minute * 10
+	}
+	return &Supervisor{cmd: c, options: options, status: SuperviseStopped}
+}
+
+type Supervisor struct {
+	cmd     *Cmd
+	options RestartOptions
+
+	mu       sync.Mutex
+	status   SuperviseStatus
+	state    *StartState
+	last     *ExitResult //最后一次退出的结果
+	restarts int         //总重启次数
+	history  []time.Time //窗口内的重启时间
+	err      error
+	cancel   context.CancelFunc
+	done     chan struct{}
+	started  chan struct{} //第一次运行的状态可用时关闭
+}
+
+func (s *Supervisor) Run() *Supervisor {
+	return s.RunWithContext(context.Background())
+}
+
+// 启动守护，已在运行时直接返回
+func (s *Supervisor) RunWithContext(ctx context.Context) *Supervisor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		select {
+		case <-s.done:
+		default:
+			return s
+		}
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	s.started = make(chan struct{})
+	s.status = SuperviseRunning
+	s.state = nil
+	s.err = nil
+	s.history = nil
+	go s.loop(ctx, s.done)
+	return s
+}
+
+func (s *Supervisor) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		state := s.cmd.RunWithContext(ctx)
+		s.update(SuperviseRunning, state, nil)
+
+		err := state.Wait()
+		if r := state.Result(); r != nil {
+			s.mu.Lock()
+			s.last = r
+			s.mu.Unlock()
+		}
+		if ctx.Err() != nil {
+			s.update(SuperviseStopped, state, err)
+			return
+		}
+
+		if !s.shouldRestart(err) {
+			s.update(SuperviseExited, state, err)
+			return
+		}
+
+		delay, ok := s.next(time.Now())
+		if !ok {
+			err = fmt.Errorf("gave up after %d restarts in %s: %w", s.options.MaxRestarts, s.options.Window, errOrExited(err))
+			s.update(SuperviseGaveUp, state, err)
+			return
+		}
+		s.update(SuperviseBackoff, state, err)
+
+		select {
+		case <-ctx.Done():
+			s.update(SuperviseStopped, state, err)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *Supervisor) update(status SuperviseStatus, state *StartState, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.started:
+	default:
+		close(s.started)
+	}
+	s.status, s.state, s.err = status, state, err
+}
+
+// 等待本次守护第一次运行的启动结果，返回当前运行的状态，启动失败时 PID 为0、Err 为失败原因
+func (s *Supervisor) WaitStarted(ctx context.Context) (*StartState, error) {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+
+	if started == nil {
+		return nil, errors.New("supervisor not running")
+	}
+	select {
+	case <-started:
+		return s.State(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 等待当前运行就绪，进程在就绪前退出或 ctx 结束时返回错误
+func (s *Supervisor) waitReady(ctx context.Context) error {
+	state, err := s.WaitStarted(ctx)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-state.Ready():
+		return nil
+	case <-state.Done():
+		return fmt.Errorf("exited before ready: %w", errOrExited(state.Err))
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 主动停止在 loop 中已经处理，不会走到这里
+func (s *Supervisor) shouldRestart(err error) bool {
+	switch s.options.Policy {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// 计算下次重启的延迟，窗口内重启次数超限时返回false
+func (s *Supervisor) next(now time.Time) (delay time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.history[:0]
+	for _, t := range s.history {
+		if now.Sub(t) < s.options.Window {
+			history = append(history, t)
+		}
+	}
+	s.history = history
+
+	if s.options.MaxRestarts > 0 && len(s.history) >= s.options.MaxRestarts {
+		return 0, false
+	}
+
+	//指数退避: delay * 2^n, n为窗口内已重启次数
+	d := float64(s.options.Delay) * math.Pow(2, float64(len(s.history)))
+	d = math.Min(d, float64(s.options.MaxDelay))
+	d += d * s.options.Jitter * (rand.Float64()*2 - 1)
+
+	s.history = append(s.history, now)
+	s.restarts++
+	return time.Duration(d), true
+}
+
+// 停止守护并等待进程退出
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// 向当前运行的进程(及其进程组)发送信号，windows 上只支持 KILL 和 TERM(结束进程树)
+func (s *Supervisor) Signal(sig syscall.Signal) error {
+	state := s.State()
+	if state == nil || state.PID == 0 || isClosed(state.Done()) {
+		return errors.New("process not running")
+	}
+	if err := signalSupported(sig); err != nil {
+		return err
+	}
+	return sysSignal(state.PID, sig)
+}
+
+func (s *Supervisor) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// 等待守护结束，返回最后一次退出的错误
+func (s *Supervisor) Wait() error {
+	if done := s.Done(); done != nil {
+		<-done
+	}
+	return s.Err()
+}
+
+func (s *Supervisor) Status() SuperviseStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// 当前(或最后一次)运行的状态
+func (s *Supervisor) State() *StartState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// 最后一次退出的结果，还没有退出过或未能启动时为 nil
+func (s *Supervisor) LastExit() *ExitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func errOrExited(err error) error {
+	if err == nil {
+		return errors.New("exited")
+	}
+	return err
+}
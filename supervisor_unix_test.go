@@ -0,0 +1,73 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy   RestartPolicy
+		command  string
+		status   SuperviseStatus
+		restarts int
+	}{
+		{RestartNever, "exit 1", SuperviseExited, 0},
+		{RestartOnFailure, "exit 0", SuperviseExited, 0},
+		{RestartOnFailure, "exit 1", SuperviseGaveUp, 2},
+		{RestartAlways, "exit 0", SuperviseGaveUp, 2},
+		{RestartUnlessStopped, "kill -SEGV $$", SuperviseGaveUp, 2},
+		{RestartUnlessStopped, "kill -KILL $$", SuperviseGaveUp, 2},
+	} {
+		s := Supervise(Shell(c.command), RestartOptions{
+			Policy:      c.policy,
+			Delay:       time.Millisecond * 10,
+			MaxRestarts: 2,
+		}).Run()
+		s.Wait()
+
+		if s.Status() != c.status || s.Restarts() != c.restarts {
+			t.Errorf("%s %q: got %s after %d restarts, want %s after %d", c.policy, c.command, s.Status(), s.Restarts(), c.status, c.restarts)
+		}
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s := Supervise(Shell("sleep 10"), RestartOptions{Policy: RestartUnlessStopped}).Run()
+	time.Sleep(time.Millisecond * 100)
+	s.Stop()
+
+	if s.Status() != SuperviseStopped || s.Restarts() != 0 {
+		t.Fatalf("got %s after %d restarts", s.Status(), s.Restarts())
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	s := Supervise(New("true"), RestartOptions{
+		Policy:      RestartAlways,
+		Delay:       time.Second,
+		MaxDelay:    time.Second * 3,
+		MaxRestarts: 3,
+		Window:      time.Second * 10,
+	})
+
+	base := time.Now()
+	for _, c := range []struct {
+		at    time.Duration
+		delay time.Duration
+		ok    bool
+	}{
+		{0, time.Second, true},
+		{time.Second, time.Second * 2, true},
+		{time.Second * 2, time.Second * 3, true},  //4s 被 MaxDelay 限制
+		{time.Second * 3, 0, false},               //窗口内已重启3次
+		{time.Second * 11, time.Second * 2, true}, //前两次已移出窗口
+	} {
+		delay, ok := s.next(base.Add(c.at))
+		if delay != c.delay || ok != c.ok {
+			t.Errorf("at %s: got %s %v, want %s %v", c.at, delay, ok, c.delay, c.ok)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import "sync"
 //顺序执行
 type Runner struct {
 	tasks []Task
-	wg    sync.WaitGroup
 }
 
 type Task struct {
@@ -25,13 +24,14 @@ func (p *Runner) Parallel(run ...func()) {
 
 func (p *Runner) Run() {
 	if p != nil {
+		var wg sync.WaitGroup //同一个 Cmd 可能同时有多次运行在执行
 		for _, task := range p.tasks {
 			if task.Parallel {
-				bgRun(&p.wg, task.Run)
+				bgRun(&wg, task.Run)
 			} else {
 				task.Run()
 			}
 		}
-		p.wg.Wait()
+		wg.Wait()
 	}
 }
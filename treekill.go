@@ -0,0 +1,25 @@
+package cmd
+
+import "sync"
+
+// 停止时结束整个进程树，包括调用 setsid 或修改了进程组而离开进程组的子孙进程。
+// 运行期间定时通过 /proc 记录子孙进程，并把当前进程设为 subreaper，孤儿进程会
+// 被过继给当前进程而不是 init，再通过启动时加入的环境变量 CMD_TREE_KILL 识别归属；
+// 停止流程的每一步都向记录的所有进程发送信号，进程退出后结束残留的进程。
+//
+// subreaper 只在有 TreeKill 运行时开启。只回收属于进程树并过继给当前进程的孤儿进程：
+// 运行时记录到的，以及与启动的进程在同一进程组中的；程序中其他的子进程(包括直接用 os/exec 启动的)
+// 不受影响。开启期间其他来源的孤儿进程也会过继过来，它们退出后不会被回收。
+// 目前只支持 linux，windows 本身就结束整个进程树
+func (c *Cmd) TreeKill() *Cmd {
+	return c.With(runOption(prepareTreeKill))
+}
+
+// 本次运行的进程树
+type treeKill struct {
+	mu     sync.Mutex
+	root   int
+	procs  map[int]uint64 //记录的子孙进程: PID -> 启动时间
+	marker []byte         //本次运行的环境变量标记
+	stop   chan struct{}
+}
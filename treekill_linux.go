@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	prSetChildSubreaper = 36
+	treeKillEnv         = "CMD_TREE_KILL" //标记进程树的环境变量
+)
+
+var subreaper struct {
+	mu      sync.Mutex
+	refs    int            //运行中的 TreeKill，为0时取消 subreaper
+	roots   map[int]bool   //运行中的进程树的根进程，也是其进程组
+	adopted map[int]uint64 //过继给当前进程的进程树成员: PID -> 启动时间
+	reaper  sync.Once
+}
+
+// 把当前进程设为 subreaper，子孙进程成为孤儿后过继给当前进程；同时在后台回收属于进程树的孤儿进程
+func acquireSubreaper() error {
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+
+	if subreaper.refs == 0 {
+		if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); e != 0 {
+			return fmt.Errorf("set child subreaper: %w", e)
+		}
+	}
+	subreaper.refs++
+
+	subreaper.reaper.Do(func() {
+		subreaper.roots, subreaper.adopted = map[int]bool{}, map[int]uint64{}
+		sigchld := make(chan os.Signal, 1)
+		signal.Notify(sigchld, syscall.SIGCHLD)
+		go func() {
+			ticker := time.NewTicker(time.Second * 10) //信号可能合并，定时补充
+			defer ticker.Stop()
+			for {
+				select {
+				case <-sigchld:
+				case <-ticker.C:
+				}
+				reapOrphans()
+			}
+		}()
+	})
+	return nil
+}
+
+// 没有运行中的 TreeKill 时取消 subreaper，已经过继过来的进程仍由 reapOrphans 回收
+func releaseSubreaper() {
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+	if subreaper.refs--; subreaper.refs == 0 {
+		syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 0, 0)
+	}
+}
+
+// 回收已退出的、属于进程树的孤儿进程；根进程由 os/exec 回收，其他子进程不处理
+func reapOrphans() {
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+
+	if len(subreaper.roots) == 0 && len(subreaper.adopted) == 0 {
+		return
+	}
+
+	self := os.Getpid()
+	procs := map[int]procInfo{}
+	for _, p := range allProcs() {
+		procs[p.PID] = p
+		if p.PPID != self || p.State != 'Z' || subreaper.roots[p.PID] {
+			continue
+		}
+		//退出前没来得及记录的，按进程组识别
+		if start, ok := subreaper.adopted[p.PID]; (ok && start == p.StartTime) || subreaper.roots[p.PGID] {
+			var ws syscall.WaitStatus
+			syscall.Wait4(p.PID, &ws, syscall.WNOHANG, nil)
+			delete(procs, p.PID)
+		}
+	}
+
+	for pid, start := range subreaper.adopted {
+		if p, ok := procs[pid]; !ok || p.StartTime != start {
+			delete(subreaper.adopted, pid)
+		}
+	}
+}
+
+var treeSeq atomic.Int64
+
+// 启动前在环境变量中加入本次运行的标记，过继给当前进程的孤儿进程通过它识别归属
+func prepareTreeKill(_ *exec.Cmd, r *run) error {
+	if err := acquireSubreaper(); err != nil {
+		return err
+	}
+
+	marker := fmt.Sprintf("%s=%d-%d", treeKillEnv, os.Getpid(), treeSeq.Add(1))
+	t := &treeKill{procs: map[int]uint64{}, marker: []byte(marker + "\x00"), stop: make(chan struct{})}
+
+	r.starting = append(r.starting, func(cmd *exec.Cmd) error {
+		cmd.Env = append(cmd.Environ(), marker) //在 Envs 等选项之后加入
+		return nil
+	})
+	r.cleanup.Append(t.cleanup) //启动失败时也会执行
+	r.started = append(r.started, t.started)
+	r.signalers = append(r.signalers, t.signal)
+	return nil
+}
+
+// 启动后每秒记录一次子孙进程
+func (t *treeKill) started(pid int) error {
+	t.mu.Lock()
+	t.root = pid
+	t.mu.Unlock()
+
+	subreaper.mu.Lock()
+	subreaper.roots[pid] = true
+	subreaper.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			t.scan()
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// 记录新出现的子孙进程，返回仍在运行的
+func (t *treeKill) scan() (alive []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.root == 0 {
+		return
+	}
+
+	procs := map[int]procInfo{}
+	children := map[int][]procInfo{}
+	for _, p := range allProcs() {
+		procs[p.PID] = p
+		children[p.PPID] = append(children[p.PPID], p)
+	}
+
+	//从启动的进程、已记录的进程和带有标记的孤儿进程出发查找子孙进程
+	queue := []int{t.root}
+	for pid := range t.procs {
+		queue = append(queue, pid)
+	}
+	self := os.Getpid()
+	for _, c := range children[self] {
+		if _, ok := t.procs[c.PID]; !ok && c.PID != t.root && t.marked(c.PID) {
+			t.procs[c.PID] = c.StartTime
+			queue = append(queue, c.PID)
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		for _, c := range children[queue[i]] {
+			if _, ok := t.procs[c.PID]; !ok {
+				t.procs[c.PID] = c.StartTime
+				queue = append(queue, c.PID)
+			}
+		}
+	}
+
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+	for pid, start := range t.procs {
+		p, ok := procs[pid]
+		if ok && p.StartTime == start && p.PPID == self {
+			subreaper.adopted[pid] = start //已过继给当前进程，由 reapOrphans 回收
+		}
+		switch {
+		case !ok || p.StartTime != start: //已退出，PID 可能已被复用
+			delete(t.procs, pid)
+		case p.State == 'Z': //已退出，等待父进程回收
+		default:
+			alive = append(alive, pid)
+		}
+	}
+	return
+}
+
+func (t *treeKill) marked(pid int) bool {
+	environ, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/environ")
+	return err == nil && (bytes.HasPrefix(environ, t.marker) || bytes.Contains(environ, append([]byte{0}, t.marker...)))
+}
+
+// 向不在进程组 pgid 中的子孙进程发送信号(进程组已单独发送过)
+func (t *treeKill) signal(pgid int, sig syscall.Signal) {
+	for _, pid := range t.scan() {
+		if g, err := syscall.Getpgid(pid); err == nil && g != pgid {
+			syscall.Kill(pid, sig)
+		}
+	}
+}
+
+// 进程退出后结束残留的子孙进程并回收；启动失败时也会执行
+func (t *treeKill) cleanup() {
+	close(t.stop)
+
+	for i := 0; i < 50; i++ {
+		alive := t.scan()
+		if len(alive) == 0 {
+			break
+		}
+		for _, pid := range alive {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	reapOrphans()
+
+	t.mu.Lock()
+	root := t.root
+	t.mu.Unlock()
+	subreaper.mu.Lock()
+	delete(subreaper.roots, root)
+	subreaper.mu.Unlock()
+	releaseSubreaper()
+}
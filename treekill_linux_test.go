@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTreeKill(t *testing.T) {
+	//离开进程组的子孙进程也被结束
+	pidfile := t.TempDir() + "/pid"
+	s := Shell(`setsid sleep 30 & echo $! >` + pidfile + `; sleep 30`).TreeKill().Run()
+	time.Sleep(time.Millisecond * 300)
+	data, _ := os.ReadFile(pidfile)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	p, err := readProc(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	if q, err := readProc(p.PID); err == nil && q.StartTime == p.StartTime && q.State != 'Z' {
+		t.Fatalf("pid %d still running", p.PID)
+	}
+
+	//标记在 Envs 之后也存在
+	out, _, err := Shell(`echo $CMD_TREE_KILL`).TreeKill().With(Envs([]string{"PATH=" + os.Getenv("PATH")})).Output()
+	if err != nil || !strings.HasPrefix(string(out), strconv.Itoa(os.Getpid())+"-") {
+		t.Fatalf("marker %q %v", out, err)
+	}
+}
+
+// 进程树中的孤儿进程被回收，程序中其他子进程仍由 os/exec 回收
+func TestTreeKillReapOrphans(t *testing.T) {
+	pidfile := t.TempDir() + "/pid"
+	s := Shell(`(sleep 0.2 >/dev/null & echo $! >` + pidfile + `); sleep 10`).TreeKill().Run()
+	defer s.Stop()
+
+	for i := 0; i < 20; i++ {
+		err := exec.Command("sh", "-c", "sleep 0.01; exit 3").Run()
+		if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 3 {
+			t.Fatalf("os/exec child: %v", err)
+		}
+	}
+
+	data, _ := os.ReadFile(pidfile)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	for i := 0; i < 30; i++ {
+		if _, err := readProc(pid); err != nil {
+			return
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	p, _ := readProc(pid)
+	t.Fatalf("orphan %d not reaped, state %c", pid, p.State)
+}
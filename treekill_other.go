@@ -0,0 +1,16 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+func prepareTreeKill(*exec.Cmd, *run) error {
+	if runtime.GOOS == "windows" {
+		return nil //taskkill /t 已经结束整个进程树
+	}
+	return errors.New("tree kill is only supported on linux")
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+// 进程树的资源使用，各项为所有进程之和
+type Usage struct {
+	Time       time.Time     `json:"time"`
+	PIDs       []int         `json:"pids"`        //进程树中的所有进程，第一个为启动的进程
+	CPU        float64       `json:"cpu"`         //两次采样之间的CPU使用率(%)，100表示占满一个核
+	CPUTime    time.Duration `json:"cpu_time"`    //用户态和内核态的CPU时间
+	RSS        int64         `json:"rss"`         //常驻内存字节数
+	FDs        int           `json:"fds"`         //打开的文件数
+	Threads    int           `json:"threads"`     //线程数
+	ReadBytes  int64         `json:"read_bytes"`  //从存储读取的字节数
+	WriteBytes int64         `json:"write_bytes"` //写入存储的字节数
+}
+
+// 进程信息，来自 /proc，其他系统不支持
+type procInfo struct {
+	PID        int
+	PPID       int
+	PGID       int           //进程组
+	State      byte          //R、S、Z 等
+	StartTime  uint64        //系统启动后的时钟周期数，用于识别复用的PID
+	CPU        time.Duration //用户态和内核态的CPU时间
+	RSS        int64
+	Threads    int
+	FDs        int
+	ReadBytes  int64
+	WriteBytes int64
+}
+
+// 按 interval 定时采样启动的进程及其所有子孙进程(包括离开进程组的)，进程退出后停止
+func (s *StartState) Sample(interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	sp := &Sampler{pid: s.PID}
+	go sp.run(s.Done(), interval)
+	return sp
+}
+
+type Sampler struct {
+	pid int
+
+	mu          sync.Mutex
+	last        Usage
+	err         error
+	subscribers []chan Usage
+	closed      bool
+}
+
+func (sp *Sampler) run(done <-chan struct{}, interval time.Duration) {
+	defer sp.close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sp.sample()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			sp.sample()
+		}
+	}
+}
+
+func (sp *Sampler) sample() {
+	now := time.Now()
+	procs, err := procTree(sp.pid)
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.err = err; err != nil {
+		return
+	}
+
+	u := Usage{Time: now}
+	for _, p := range procs {
+		u.PIDs = append(u.PIDs, p.PID)
+		u.CPUTime += p.CPU
+		u.RSS += p.RSS
+		u.FDs += p.FDs
+		u.Threads += p.Threads
+		u.ReadBytes += p.ReadBytes
+		u.WriteBytes += p.WriteBytes
+	}
+
+	//已退出的子进程的CPU时间不再计入，差值为负时记为0
+	if prev := sp.last; !prev.Time.IsZero() {
+		if d := u.CPUTime - prev.CPUTime; d > 0 {
+			u.CPU = float64(d) / float64(now.Sub(prev.Time)) * 100
+		}
+	}
+	sp.last = u
+
+	for _, ch := range sp.subscribers {
+		select {
+		case ch <- u:
+		default: //订阅者处理不过来时丢弃
+		}
+	}
+}
+
+// 最近一次采样的结果，还没有采样成功时为零值
+func (sp *Sampler) Snapshot() (Usage, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.last, sp.err
+}
+
+// 订阅每次采样的结果，进程退出后关闭
+func (sp *Sampler) Subscribe() <-chan Usage {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	ch := make(chan Usage, 1)
+	if sp.closed {
+		close(ch)
+	} else {
+		sp.subscribers = append(sp.subscribers, ch)
+	}
+	return ch
+}
+
+func (sp *Sampler) close() {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.closed = true
+	for _, ch := range sp.subscribers {
+		close(ch)
+	}
+	sp.subscribers = nil
+}